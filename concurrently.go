@@ -0,0 +1,51 @@
+package pivnet
+
+import "sync"
+
+// forEachConcurrently calls fn for each index in [0, count) on up to
+// concurrency goroutines. No new calls are started after the first failure,
+// whose error is returned once the calls in flight have finished.
+func forEachConcurrently(count int, concurrency int, fn func(i int) error) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	work := make(chan int)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range work {
+				err := fn(index)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+
+		if failed {
+			break
+		}
+
+		work <- i
+	}
+	close(work)
+
+	wg.Wait()
+
+	return firstErr
+}
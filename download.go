@@ -0,0 +1,603 @@
+package pivnet
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const (
+	maxDownloadRedirects       = 10
+	defaultDownloadConcurrency = 4
+)
+
+type DownloadOptions struct {
+	// AcceptEULA accepts the release's EULA and retries the download once
+	// when Pivnet reports that the EULA has not been accepted.
+	AcceptEULA bool
+
+	// Cache, if set, is consulted before downloading product files that have
+	// a checksum and is populated with the ones it does not yet hold.
+	Cache *DownloadCache
+
+	// SignatureKeyring, if set, requires each product file to have a detached
+	// signature made by one of its keys. Files are staged and verified before
+	// being written to their destination.
+	SignatureKeyring *Keyring
+
+	// Lockfile, if set, records the release, object key, size and checksums
+	// of every product file that is downloaded.
+	Lockfile *Lockfile
+
+	// ExtractDir, if set, is the directory that .tgz, .tar.gz and .zip
+	// product files are extracted into. Other product files are downloaded
+	// without being extracted. Archives are extracted into a staging
+	// directory inside ExtractDir and moved into place only once the
+	// download has been verified, so a failed download leaves the files
+	// already in ExtractDir alone.
+	ExtractDir string
+}
+
+type DownloadResult struct {
+	// FinalURL is the URL the file contents were served from. For most
+	// product files this is a signed storage URL reached by following the
+	// redirect from Pivnet.
+	FinalURL string `json:"final_url,omitempty" yaml:"final_url,omitempty"`
+	Size     int64  `json:"size,omitempty" yaml:"size,omitempty"`
+
+	// Cached is true when the file was served from DownloadOptions.Cache
+	// without contacting the storage host.
+	Cached bool `json:"cached,omitempty" yaml:"cached,omitempty"`
+
+	// MD5 and SHA256 are computed over the downloaded contents.
+	// They are empty for files served from the cache.
+	MD5    string `json:"md5,omitempty" yaml:"md5,omitempty"`
+	SHA256 string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+
+	// Extracted is set when the product file was extracted into
+	// DownloadOptions.ExtractDir.
+	Extracted *ExtractResult `json:"extracted,omitempty" yaml:"extracted,omitempty"`
+}
+
+type ErrTooManyRedirects struct {
+	URL string
+}
+
+func (e ErrTooManyRedirects) Error() string {
+	return fmt.Sprintf(
+		"stopped after %d redirects downloading %s",
+		maxDownloadRedirects,
+		e.URL,
+	)
+}
+
+type ErrDownloadFailed struct {
+	ResponseCode int    `json:"response_code" yaml:"response_code"`
+	URL          string `json:"url" yaml:"url"`
+}
+
+func (e ErrDownloadFailed) Error() string {
+	return fmt.Sprintf(
+		"%d - download from %s failed",
+		e.ResponseCode,
+		e.URL,
+	)
+}
+
+type ErrChecksumMismatch struct {
+	ProductFileID int    `json:"product_file_id" yaml:"product_file_id"`
+	Algorithm     string `json:"algorithm" yaml:"algorithm"`
+	Expected      string `json:"expected" yaml:"expected"`
+	Actual        string `json:"actual" yaml:"actual"`
+}
+
+func (e ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf(
+		"%s mismatch for product file %d: expected %s, got %s",
+		e.Algorithm,
+		e.ProductFileID,
+		e.Expected,
+		e.Actual,
+	)
+}
+
+// Download copies the file behind a Pivnet download link to writer.
+func (c Client) Download(writer io.Writer, downloadLink string) (DownloadResult, error) {
+	resp, err := c.MakeDownloadRequest(downloadLink)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+	defer resp.Body.Close()
+
+	result := DownloadResult{
+		FinalURL: resp.Request.URL.String(),
+	}
+
+	c.logger.Debug("Copying body", logger.Data{"finalURL": redactedURL(resp.Request.URL)})
+
+	result.Size, err = io.Copy(writer, resp.Body)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (p ProductFilesService) download(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	if options.ExtractDir != "" {
+		return p.downloadExtracted(writer, productSlug, releaseID, productFile, options)
+	}
+
+	if options.SignatureKeyring != nil {
+		return p.downloadVerified(writer, productSlug, releaseID, productFile, options)
+	}
+
+	return p.downloadCached(writer, productSlug, releaseID, productFile, options)
+}
+
+// downloadVerified stages the product file in a temporary file and only
+// copies it to writer once its signature is verified.
+func (p ProductFilesService) downloadVerified(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	staged, err := ioutil.TempFile("", "pivnet-download-")
+	if err != nil {
+		return DownloadResult{}, err
+	}
+	defer os.Remove(staged.Name())
+
+	result, err := p.downloadCached(staged, productSlug, releaseID, productFile, options)
+
+	closeErr := staged.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	err = p.verifyFile(staged.Name(), productFile, *options.SignatureKeyring)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	err = copyFileTo(writer, staged.Name())
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	return result, nil
+}
+
+func (p ProductFilesService) downloadCached(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	if key, ok := cacheKey(productFile); ok && options.Cache != nil {
+		return options.Cache.fetch(
+			key,
+			func(w io.Writer) (DownloadResult, error) {
+				return p.fetch(w, productSlug, releaseID, productFile, options)
+			},
+			func(objectPath string) error {
+				return copyFileTo(writer, objectPath)
+			},
+		)
+	}
+
+	return p.fetch(writer, productSlug, releaseID, productFile, options)
+}
+
+// fetch downloads a product file from Pivnet, bypassing any cache, and
+// verifies the checksums Pivnet advertises for it.
+func (p ProductFilesService) fetch(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	downloadLink, err := productFile.DownloadLink()
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	p.client.logger.Debug("Downloading file", logger.Data{"downloadLink": downloadLink})
+
+	checksums := newChecksumWriter()
+	writer = io.MultiWriter(writer, checksums)
+
+	result, err := p.client.Download(writer, downloadLink)
+	if _, ok := err.(ErrUnavailableForLegalReasons); ok && options.AcceptEULA {
+		err = p.acceptEULA(productSlug, releaseID)
+		if err != nil {
+			return DownloadResult{}, err
+		}
+
+		p.client.logger.Debug("Retrying download", logger.Data{"downloadLink": downloadLink})
+
+		result, err = p.client.Download(writer, downloadLink)
+	}
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	err = checksums.verify(productFile)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	result.MD5 = checksums.MD5()
+	result.SHA256 = checksums.SHA256()
+
+	return result, nil
+}
+
+func (p ProductFilesService) acceptEULA(productSlug string, releaseID int) error {
+	releases := ReleasesService{client: p.client, l: p.client.logger}
+
+	release, err := releases.Get(productSlug, releaseID)
+	if err != nil {
+		return err
+	}
+
+	data := logger.Data{
+		"productSlug": productSlug,
+		"releaseID":   releaseID,
+		"version":     release.Version,
+	}
+	if release.EULA != nil {
+		data["eulaSlug"] = release.EULA.Slug
+		data["eulaName"] = release.EULA.Name
+	}
+
+	p.client.logger.Info("Accepting EULA", data)
+
+	eulas := EULAsService{client: p.client}
+	return eulas.Accept(productSlug, releaseID)
+}
+
+// MakeDownloadRequest requests a Pivnet download link and follows any
+// redirects explicitly. Pivnet credentials are only sent to the Pivnet host;
+// requests to any other host, e.g. signed storage URLs, carry no
+// Authorization or Content-Type header.
+//
+// The returned response has a 200 status code and its Request field holds
+// the final URL. The caller is responsible for closing the body.
+func (c Client) MakeDownloadRequest(downloadLink string) (*http.Response, error) {
+	req, err := c.CreateRequest("POST", downloadLink, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.httpClient()
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for redirects := 0; ; redirects++ {
+		c.logger.Debug("Making download request", logger.Data{
+			"method": req.Method,
+			"url":    redactedURL(req.URL),
+		})
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		c.logger.Debug("Response status code", logger.Data{"status code": resp.StatusCode})
+
+		if !isRedirect(resp.StatusCode) {
+			if resp.StatusCode == http.StatusOK {
+				c.logger.Debug("Downloading file", logger.Data{"finalURL": redactedURL(req.URL)})
+				return resp, nil
+			}
+
+			defer resp.Body.Close()
+
+			if c.isPivnetHost(req.URL) {
+				return nil, c.responseError(resp)
+			}
+
+			return nil, ErrDownloadFailed{
+				ResponseCode: resp.StatusCode,
+				URL:          redactedURL(req.URL),
+			}
+		}
+
+		location, err := resp.Location()
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if redirects == maxDownloadRedirects {
+			return nil, ErrTooManyRedirects{URL: redactedURL(location)}
+		}
+
+		c.logger.Debug("Following redirect", logger.Data{
+			"status code": resp.StatusCode,
+			"location":    redactedURL(location),
+		})
+
+		req, err = c.redirectRequest(req, location, resp.StatusCode)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c Client) redirectRequest(
+	previous *http.Request,
+	location *url.URL,
+	statusCode int,
+) (*http.Request, error) {
+	// 307 and 308 require the method to be preserved;
+	// every other redirect is followed with a GET.
+	method := "GET"
+	if statusCode == http.StatusTemporaryRedirect || statusCode == http.StatusPermanentRedirect {
+		method = previous.Method
+	}
+
+	req, err := http.NewRequest(method, location.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.isPivnetHost(location) {
+		c.addPivnetHeaders(req)
+	} else {
+		req.Header.Add("User-Agent", c.userAgent)
+	}
+
+	return req, nil
+}
+
+func (c Client) isPivnetHost(u *url.URL) bool {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == base.Scheme && u.Host == base.Host
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusSeeOther,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// redactedURL drops the query string, which for signed storage URLs
+// contains the signature, so the URL can safely appear in errors.
+func redactedURL(u *url.URL) string {
+	redacted := *u
+	redacted.RawQuery = ""
+	return redacted.String()
+}
+
+// downloadToPath writes the product file to a staging file next to
+// filePath and renames it into place once it has been verified, so an
+// interrupted download never leaves a partial file at filePath. If
+// checkSize is set, it is called with the size of the staged file as part
+// of the verification.
+func (p ProductFilesService) downloadToPath(
+	filePath string,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	options DownloadOptions,
+	checkSize func(size int64) error,
+) (DownloadResult, error) {
+	staged, err := stagingFile(filePath)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+	staged.Close()
+	defer os.Remove(staged.Name())
+
+	result, err := p.placeFile(staged.Name(), productSlug, releaseID, productFile, options)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	if checkSize != nil {
+		info, err := os.Stat(staged.Name())
+		if err != nil {
+			return DownloadResult{}, err
+		}
+
+		err = checkSize(info.Size())
+		if err != nil {
+			return DownloadResult{}, err
+		}
+	}
+
+	if options.SignatureKeyring != nil {
+		err = p.verifyFile(staged.Name(), productFile, *options.SignatureKeyring)
+		if err != nil {
+			return DownloadResult{}, err
+		}
+	}
+
+	if options.ExtractDir != "" {
+		result.Extracted, err = p.extractFile(staged.Name(), productFile, options.ExtractDir)
+		if err != nil {
+			return DownloadResult{}, err
+		}
+	}
+
+	err = os.Rename(staged.Name(), filePath)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	return result, nil
+}
+
+// placeFile writes the product file to filePath from the cache or Pivnet.
+func (p ProductFilesService) placeFile(
+	filePath string,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	if key, ok := cacheKey(productFile); ok && options.Cache != nil {
+		return options.Cache.fetch(
+			key,
+			func(w io.Writer) (DownloadResult, error) {
+				return p.fetch(w, productSlug, releaseID, productFile, options)
+			},
+			func(objectPath string) error {
+				return linkOrCopy(objectPath, filePath)
+			},
+		)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	result, err := p.fetch(file, productSlug, releaseID, productFile, options)
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	return result, nil
+}
+
+// stagingFile returns a new empty file next to filePath for content that is
+// not yet ready to be moved into place.
+func stagingFile(filePath string) (*os.File, error) {
+	return ioutil.TempFile(
+		filepath.Dir(filePath),
+		"."+filepath.Base(filePath)+".partial-",
+	)
+}
+
+// downloadConcurrently calls download for each index in [0, count) on up to
+// concurrency goroutines. No new downloads are started after the first
+// failure, whose error is returned.
+func downloadConcurrently(
+	count int,
+	concurrency int,
+	download func(i int) (DownloadedFile, error),
+) ([]DownloadedFile, error) {
+	if concurrency < 1 {
+		concurrency = defaultDownloadConcurrency
+	}
+
+	downloaded := make([]DownloadedFile, count)
+
+	err := forEachConcurrently(count, concurrency, func(i int) error {
+		var err error
+		downloaded[i], err = download(i)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return downloaded, nil
+}
+
+// checksumWriter computes the checksums Pivnet records for product files
+// over everything written to it.
+type checksumWriter struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+}
+
+func newChecksumWriter() *checksumWriter {
+	return &checksumWriter{
+		md5:    md5.New(),
+		sha256: sha256.New(),
+	}
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	c.md5.Write(p)
+	c.sha256.Write(p)
+	return len(p), nil
+}
+
+func (c *checksumWriter) MD5() string {
+	return hex.EncodeToString(c.md5.Sum(nil))
+}
+
+func (c *checksumWriter) SHA256() string {
+	return hex.EncodeToString(c.sha256.Sum(nil))
+}
+
+// verify compares the written checksums against those Pivnet advertises
+// for the product file. Checksums Pivnet does not advertise are skipped.
+func (c *checksumWriter) verify(productFile ProductFile) error {
+	if productFile.SHA256 != "" && productFile.SHA256 != c.SHA256() {
+		return ErrChecksumMismatch{
+			ProductFileID: productFile.ID,
+			Algorithm:     "sha256",
+			Expected:      productFile.SHA256,
+			Actual:        c.SHA256(),
+		}
+	}
+
+	if productFile.MD5 != "" && productFile.MD5 != c.MD5() {
+		return ErrChecksumMismatch{
+			ProductFileID: productFile.ID,
+			Algorithm:     "md5",
+			Expected:      productFile.MD5,
+			Actual:        c.MD5(),
+		}
+	}
+
+	return nil
+}
+
+func copyFileTo(writer io.Writer, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(writer, f)
+	return err
+}
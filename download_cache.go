@@ -0,0 +1,451 @@
+package pivnet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const (
+	cacheObjectsDir = "objects"
+	cacheLocksDir   = "locks"
+	cacheTmpDir     = "tmp"
+	cachePruneLock  = "prune.lock"
+)
+
+// DownloadCache is a content-addressed store of downloaded product files,
+// keyed by their SHA256 or, failing that, MD5 checksum. It may be shared by
+// any number of processes on the same host.
+//
+// Cached files are read-only. Files are hardlinked into destination
+// directories where possible, so they should not be modified in place;
+// entries that no longer match their checksum are downloaded again.
+type DownloadCache struct {
+	dir      string
+	maxBytes int64
+	logger   logger.Logger
+}
+
+type CacheEntry struct {
+	// Key is the checksum algorithm and hex digest, e.g. "sha256/ab12...".
+	Key      string    `json:"key" yaml:"key"`
+	Path     string    `json:"path" yaml:"path"`
+	Size     int64     `json:"size" yaml:"size"`
+	LastUsed time.Time `json:"last_used" yaml:"last_used"`
+}
+
+type PruneResult struct {
+	Removed    []CacheEntry `json:"removed" yaml:"removed"`
+	FreedBytes int64        `json:"freed_bytes" yaml:"freed_bytes"`
+}
+
+// NewDownloadCache returns a cache rooted at dir, creating it if necessary.
+// When maxBytes is greater than zero, the least recently used entries are
+// evicted after each addition to keep the cache within that size.
+func NewDownloadCache(dir string, maxBytes int64, logger logger.Logger) (*DownloadCache, error) {
+	for _, d := range []string{cacheObjectsDir, cacheLocksDir, cacheTmpDir} {
+		err := os.MkdirAll(filepath.Join(dir, d), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &DownloadCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		logger:   logger,
+	}, nil
+}
+
+// Entries returns every file in the cache, least recently used first.
+func (c *DownloadCache) Entries() ([]CacheEntry, error) {
+	var entries []CacheEntry
+
+	root := filepath.Join(c.dir, cacheObjectsDir)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		key, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, CacheEntry{
+			Key:      filepath.ToSlash(key),
+			Path:     path,
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(cacheEntriesByLastUsed(entries))
+
+	return entries, nil
+}
+
+// Prune removes the least recently used entries until the cache holds at
+// most maxBytes, skipping entries that are in use. Prune(0) empties the
+// cache. Partial files left behind by interrupted downloads and lock files
+// that are not in use are also removed.
+func (c *DownloadCache) Prune(maxBytes int64) (PruneResult, error) {
+	unlock, err := lockFile(filepath.Join(c.dir, cachePruneLock))
+	if err != nil {
+		return PruneResult{}, err
+	}
+	defer unlock()
+
+	err = c.removePartials()
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	var result PruneResult
+	for _, e := range entries {
+		if total <= maxBytes {
+			break
+		}
+
+		unlockKey, ok, err := tryLockFile(c.lockPath(e.Key))
+		if err != nil {
+			return result, err
+		}
+
+		if !ok {
+			c.logger.Debug("Skipping cache entry in use", logger.Data{"key": e.Key})
+			continue
+		}
+
+		err = os.Remove(e.Path)
+		unlockKey()
+		if err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+
+		c.logger.Debug("Removed cache entry", logger.Data{"key": e.Key, "size": e.Size})
+
+		total -= e.Size
+		result.FreedBytes += e.Size
+		result.Removed = append(result.Removed, e)
+	}
+
+	err = c.removeLocks()
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// fetch serves the object for key, populating the cache first on a miss.
+// The key is locked for the duration so concurrent downloads of the same
+// file, in this or other processes, hit the network once.
+func (c *DownloadCache) fetch(
+	key string,
+	populate func(io.Writer) (DownloadResult, error),
+	serve func(objectPath string) error,
+) (DownloadResult, error) {
+	result, err := c.fetchLocked(key, populate, serve)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	if !result.Cached && c.maxBytes > 0 {
+		_, err = c.Prune(c.maxBytes)
+		if err != nil {
+			return DownloadResult{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (c *DownloadCache) fetchLocked(
+	key string,
+	populate func(io.Writer) (DownloadResult, error),
+	serve func(objectPath string) error,
+) (DownloadResult, error) {
+	unlock, err := lockFile(c.lockPath(key))
+	if err != nil {
+		return DownloadResult{}, err
+	}
+	defer unlock()
+
+	objectPath := filepath.Join(c.dir, cacheObjectsDir, filepath.FromSlash(key))
+
+	info, err := os.Stat(objectPath)
+	if err == nil {
+		ok, err := c.intact(key, objectPath)
+		if err != nil {
+			return DownloadResult{}, err
+		}
+
+		if ok {
+			c.logger.Debug("Download cache hit", logger.Data{"key": key})
+
+			c.touch(key, objectPath)
+
+			err = serve(objectPath)
+			if err != nil {
+				return DownloadResult{}, err
+			}
+
+			return DownloadResult{Size: info.Size(), Cached: true}, nil
+		}
+
+		c.logger.Info("Replacing corrupt cache entry", logger.Data{"key": key})
+
+		err = os.Remove(objectPath)
+		if err != nil {
+			return DownloadResult{}, err
+		}
+	} else if !os.IsNotExist(err) {
+		return DownloadResult{}, err
+	}
+
+	c.logger.Debug("Download cache miss", logger.Data{"key": key})
+
+	result, err := c.add(key, objectPath, populate)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	err = serve(objectPath)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	return result, nil
+}
+
+// intact reports whether the object for key still has the checksum it is
+// keyed by. Objects are hardlinked into destination directories, so a
+// destination modified in place modifies the object too.
+func (c *DownloadCache) intact(key string, objectPath string) (bool, error) {
+	cw := newChecksumWriter()
+	err := copyFileTo(cw, objectPath)
+	if err != nil {
+		return false, err
+	}
+
+	actual := cw.SHA256()
+	if strings.HasPrefix(key, "md5/") {
+		actual = cw.MD5()
+	}
+
+	return key[strings.Index(key, "/")+1:] == actual, nil
+}
+
+// touch marks the object for key as recently used. Only the owner of a file
+// may set its times, so in a cache shared between users this can fail, in
+// which case the entry may be evicted earlier than it otherwise would be.
+func (c *DownloadCache) touch(key string, objectPath string) {
+	now := time.Now()
+	err := os.Chtimes(objectPath, now, now)
+	if err != nil {
+		c.logger.Debug("Could not update cache entry access time", logger.Data{
+			"key":   key,
+			"error": err.Error(),
+		})
+	}
+}
+
+// add populates the object for key via a partial file which is only moved
+// into place once populate, which verifies the checksums, has succeeded.
+// The caller holds the key lock.
+func (c *DownloadCache) add(
+	key string,
+	objectPath string,
+	populate func(io.Writer) (DownloadResult, error),
+) (DownloadResult, error) {
+	partialPath := filepath.Join(c.dir, cacheTmpDir, cacheFileName(key)+".partial")
+
+	f, err := os.Create(partialPath)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+	defer os.Remove(partialPath)
+
+	result, err := populate(f)
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	err = os.Chmod(partialPath, 0444)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(objectPath), 0755)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	err = os.Rename(partialPath, objectPath)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	return result, nil
+}
+
+// removePartials removes partial files whose download is no longer running.
+func (c *DownloadCache) removePartials() error {
+	partials, err := ioutil.ReadDir(filepath.Join(c.dir, cacheTmpDir))
+	if err != nil {
+		return err
+	}
+
+	for _, partial := range partials {
+		name := strings.TrimSuffix(partial.Name(), ".partial")
+
+		unlock, ok, err := tryLockFile(filepath.Join(c.dir, cacheLocksDir, name+".lock"))
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(c.dir, cacheTmpDir, partial.Name()))
+		unlock()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeLocks removes the lock files of keys that are not in use. They are
+// recreated by the next download of the key.
+func (c *DownloadCache) removeLocks() error {
+	locksDir := filepath.Join(c.dir, cacheLocksDir)
+
+	locks, err := ioutil.ReadDir(locksDir)
+	if err != nil {
+		return err
+	}
+
+	for _, lock := range locks {
+		lockPath := filepath.Join(locksDir, lock.Name())
+
+		unlock, ok, err := tryLockFile(lockPath)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		err = os.Remove(lockPath)
+		unlock()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *DownloadCache) lockPath(key string) string {
+	return filepath.Join(c.dir, cacheLocksDir, cacheFileName(key)+".lock")
+}
+
+// cacheKey returns the cache key for a product file, preferring SHA256 over
+// MD5. Product files without either checksum cannot be cached.
+func cacheKey(productFile ProductFile) (string, bool) {
+	sha := strings.ToLower(productFile.SHA256)
+	if isHex(sha) {
+		return fmt.Sprintf("sha256/%s", sha), true
+	}
+
+	md := strings.ToLower(productFile.MD5)
+	if isHex(md) {
+		return fmt.Sprintf("md5/%s", md), true
+	}
+
+	return "", false
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func cacheFileName(key string) string {
+	return strings.Replace(key, "/", "-", -1)
+}
+
+// linkOrCopy places the file at src at dst, hardlinking where the
+// filesystem allows and copying otherwise.
+func linkOrCopy(src string, dst string) error {
+	err := os.Remove(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Link(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	err = copyFileTo(out, src)
+
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+type cacheEntriesByLastUsed []CacheEntry
+
+func (c cacheEntriesByLastUsed) Len() int           { return len(c) }
+func (c cacheEntriesByLastUsed) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c cacheEntriesByLastUsed) Less(i, j int) bool { return c[i].LastUsed.Before(c[j].LastUsed) }
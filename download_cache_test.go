@@ -0,0 +1,356 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - download cache", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		cacheDir string
+		cache    *pivnet.DownloadCache
+		maxBytes int64
+
+		releaseID     int
+		productFileID int
+		fileContents  []byte
+		productFile   pivnet.ProductFile
+		downloadPath  string
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		var err error
+		cacheDir, err = ioutil.TempDir("", "download-cache")
+		Expect(err).NotTo(HaveOccurred())
+
+		maxBytes = 0
+
+		releaseID = 12
+		productFileID = 1234
+		fileContents = []byte("some file contents")
+		downloadPath = fmt.Sprintf("/products/%s/releases/%d/product_files/%d/download", productSlug, releaseID, productFileID)
+
+		sum := sha256.Sum256(fileContents)
+		productFile = pivnet.ProductFile{
+			ID:           productFileID,
+			AWSObjectKey: "product-files/p/file.tgz",
+			SHA256:       hex.EncodeToString(sum[:]),
+			Links: &pivnet.Links{
+				Download: map[string]string{"href": downloadPath},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(cacheDir)
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		cache, err = pivnet.NewDownloadCache(cacheDir, maxBytes, fakeLogger)
+		Expect(err).NotTo(HaveOccurred())
+
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, productFileID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: productFile}),
+		)
+
+		server.RouteToHandler("POST",
+			fmt.Sprintf("%s%s", apiPrefix, downloadPath),
+			func(w http.ResponseWriter, req *http.Request) {
+				w.Write(fileContents)
+			},
+		)
+	})
+
+	downloadRequests := func() int {
+		count := 0
+		for _, req := range server.ReceivedRequests() {
+			if req.Method == "POST" {
+				count++
+			}
+		}
+		return count
+	}
+
+	download := func() (pivnet.DownloadResult, []byte, error) {
+		writer := bytes.NewBuffer(nil)
+		result, err := client.ProductFiles.DownloadForReleaseWithOptions(
+			writer,
+			productSlug,
+			releaseID,
+			productFileID,
+			pivnet.DownloadOptions{Cache: cache},
+		)
+		return result, writer.Bytes(), err
+	}
+
+	It("downloads the file once and serves it from the cache afterwards", func() {
+		result, contents, err := download()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents).To(Equal(fileContents))
+		Expect(result.Cached).To(BeFalse())
+
+		result, contents, err = download()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents).To(Equal(fileContents))
+		Expect(result.Cached).To(BeTrue())
+		Expect(result.Size).To(Equal(int64(len(fileContents))))
+
+		Expect(downloadRequests()).To(Equal(1))
+	})
+
+	It("keys entries by checksum", func() {
+		_, _, err := download()
+		Expect(err).NotTo(HaveOccurred())
+
+		entries, err := cache.Entries()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Key).To(Equal("sha256/" + productFile.SHA256))
+		Expect(entries[0].Size).To(Equal(int64(len(fileContents))))
+	})
+
+	Context("when the product file only has an MD5", func() {
+		BeforeEach(func() {
+			sum := md5.Sum(fileContents)
+			productFile.SHA256 = ""
+			productFile.MD5 = hex.EncodeToString(sum[:])
+		})
+
+		It("keys the entry by MD5", func() {
+			_, _, err := download()
+			Expect(err).NotTo(HaveOccurred())
+
+			entries, err := cache.Entries()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Key).To(Equal("md5/" + productFile.MD5))
+		})
+	})
+
+	Context("when the product file has no checksum", func() {
+		BeforeEach(func() {
+			productFile.SHA256 = ""
+		})
+
+		It("bypasses the cache", func() {
+			_, _, err := download()
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = download()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(downloadRequests()).To(Equal(2))
+
+			entries, err := cache.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Context("when the downloaded contents do not match the checksum", func() {
+		BeforeEach(func() {
+			productFile.SHA256 = "abcdef"
+		})
+
+		It("returns an ErrChecksumMismatch and caches nothing", func() {
+			_, contents, err := download()
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrChecksumMismatch{}))
+			Expect(contents).To(BeEmpty())
+
+			entries, err := cache.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Context("when downloading a release into a directory", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "download-cache-dest")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{
+					ProductFiles: []pivnet.ProductFile{productFile},
+				}),
+			)
+
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/releases/%d/file_groups", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{}),
+			)
+		})
+
+		It("places cached files into the directory", func() {
+			config := pivnet.DownloadReleaseConfig{
+				ProductSlug: productSlug,
+				ReleaseID:   releaseID,
+				Dir:         dir,
+				Options:     pivnet.DownloadOptions{Cache: cache},
+			}
+
+			_, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			os.Remove(filepath.Join(dir, "file.tgz"))
+
+			files, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files[0].Result.Cached).To(BeTrue())
+
+			contents, err := ioutil.ReadFile(filepath.Join(dir, "file.tgz"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents).To(Equal(fileContents))
+
+			Expect(downloadRequests()).To(Equal(1))
+		})
+
+		It("downloads the file again when a placed file was modified in place", func() {
+			config := pivnet.DownloadReleaseConfig{
+				ProductSlug: productSlug,
+				ReleaseID:   releaseID,
+				Dir:         dir,
+				Options:     pivnet.DownloadOptions{Cache: cache},
+			}
+
+			_, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			placed := filepath.Join(dir, "file.tgz")
+			Expect(os.Chmod(placed, 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(placed, []byte("some edited contents"), 0644)).To(Succeed())
+
+			files, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files[0].Result.Cached).To(BeFalse())
+
+			contents, err := ioutil.ReadFile(placed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents).To(Equal(fileContents))
+
+			Expect(downloadRequests()).To(Equal(2))
+		})
+	})
+
+	Describe("Prune", func() {
+		JustBeforeEach(func() {
+			_, _, err := download()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("removes entries until the cache fits", func() {
+			result, err := cache.Prune(0)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Removed).To(HaveLen(1))
+			Expect(result.FreedBytes).To(Equal(int64(len(fileContents))))
+
+			entries, err := cache.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("removes lock files that are not in use", func() {
+			_, err := cache.Prune(0)
+			Expect(err).NotTo(HaveOccurred())
+
+			locks, err := ioutil.ReadDir(filepath.Join(cacheDir, "locks"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locks).To(BeEmpty())
+		})
+
+		It("keeps entries when the cache already fits", func() {
+			result, err := cache.Prune(int64(len(fileContents)))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Removed).To(BeEmpty())
+		})
+	})
+
+	Context("when the cache has a size limit", func() {
+		var otherContents []byte
+
+		BeforeEach(func() {
+			otherContents = []byte("some other file contents")
+			maxBytes = int64(len(otherContents)) + 1
+		})
+
+		It("evicts the least recently used entries", func() {
+			_, _, err := download()
+			Expect(err).NotTo(HaveOccurred())
+
+			entries, err := cache.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			old := time.Now().Add(-time.Hour)
+			Expect(os.Chtimes(entries[0].Path, old, old)).To(Succeed())
+
+			sum := sha256.Sum256(otherContents)
+			productFile.SHA256 = hex.EncodeToString(sum[:])
+			fileContents = otherContents
+
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, productFileID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: productFile}),
+			)
+
+			_, _, err = download()
+			Expect(err).NotTo(HaveOccurred())
+
+			entries, err = cache.Entries()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Key).To(Equal("sha256/" + productFile.SHA256))
+		})
+	})
+})
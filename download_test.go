@@ -0,0 +1,240 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - download", func() {
+	var (
+		server        *ghttp.Server
+		storageServer *ghttp.Server
+		client        pivnet.Client
+		token         string
+		apiAddress    string
+		userAgent     string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		downloadLink string
+		fileContents []byte
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		storageServer = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		downloadLink = "/products/banana/releases/12/product_files/1234/download"
+		fileContents = []byte("some file contents")
+	})
+
+	AfterEach(func() {
+		server.Close()
+		storageServer.Close()
+	})
+
+	Describe("Download", func() {
+		Context("when Pivnet redirects to a storage URL on another host", func() {
+			var storageURL string
+
+			BeforeEach(func() {
+				storageURL = fmt.Sprintf("%s/bucket/some-file?X-Amz-Signature=abc", storageServer.URL())
+
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", fmt.Sprintf("%s%s", apiPrefix, downloadLink)),
+						ghttp.VerifyHeaderKV("Authorization", fmt.Sprintf("Token %s", token)),
+						ghttp.RespondWith(http.StatusFound, nil, http.Header{
+							"Location": []string{storageURL},
+						}),
+					),
+				)
+
+				storageServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/bucket/some-file", "X-Amz-Signature=abc"),
+						ghttp.VerifyHeaderKV("User-Agent", userAgent),
+						func(w http.ResponseWriter, req *http.Request) {
+							Expect(req.Header.Get("Authorization")).To(BeEmpty())
+							Expect(req.Header.Get("Content-Type")).To(BeEmpty())
+						},
+						ghttp.RespondWith(http.StatusOK, fileContents),
+					),
+				)
+			})
+
+			It("follows the redirect without Pivnet credentials", func() {
+				writer := bytes.NewBuffer(nil)
+
+				_, err := client.Download(writer, downloadLink)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(writer.Bytes()).To(Equal(fileContents))
+			})
+
+			It("returns the final storage URL and size", func() {
+				result, err := client.Download(bytes.NewBuffer(nil), downloadLink)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.FinalURL).To(Equal(storageURL))
+				Expect(result.Size).To(Equal(int64(len(fileContents))))
+			})
+
+			It("does not log the signed query string", func() {
+				_, err := client.Download(bytes.NewBuffer(nil), downloadLink)
+				Expect(err).NotTo(HaveOccurred())
+
+				fake := fakeLogger.(*loggerfakes.FakeLogger)
+				Expect(fake.DebugCallCount()).NotTo(BeZero())
+
+				for i := 0; i < fake.DebugCallCount(); i++ {
+					_, data := fake.DebugArgsForCall(i)
+					Expect(fmt.Sprint(data)).NotTo(ContainSubstring("X-Amz-Signature"))
+				}
+			})
+		})
+
+		Context("when the download link is absolute", func() {
+			BeforeEach(func() {
+				downloadLink = fmt.Sprintf("https://network.pivotal.io%s%s", apiPrefix, downloadLink)
+
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", fmt.Sprintf(
+							"%s/products/banana/releases/12/product_files/1234/download",
+							apiPrefix,
+						)),
+						ghttp.RespondWith(http.StatusOK, fileContents),
+					),
+				)
+			})
+
+			It("requests the link path on the configured host", func() {
+				writer := bytes.NewBuffer(nil)
+
+				_, err := client.Download(writer, downloadLink)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(writer.Bytes()).To(Equal(fileContents))
+			})
+		})
+
+		Context("when Pivnet redirects to itself", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", fmt.Sprintf("%s%s", apiPrefix, downloadLink)),
+						ghttp.RespondWith(http.StatusTemporaryRedirect, nil, http.Header{
+							"Location": []string{"/elsewhere"},
+						}),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/elsewhere"),
+						ghttp.VerifyHeaderKV("Authorization", fmt.Sprintf("Token %s", token)),
+						ghttp.RespondWith(http.StatusOK, fileContents),
+					),
+				)
+			})
+
+			It("keeps the Pivnet credentials and the method for a 307", func() {
+				writer := bytes.NewBuffer(nil)
+
+				_, err := client.Download(writer, downloadLink)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(writer.Bytes()).To(Equal(fileContents))
+			})
+		})
+
+		Context("when Pivnet returns a 451", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusUnavailableForLegalReasons, `{"message":"ignore me"}`),
+				)
+			})
+
+			It("returns an ErrUnavailableForLegalReasons", func() {
+				_, err := client.Download(bytes.NewBuffer(nil), downloadLink)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrUnavailableForLegalReasons{}))
+			})
+		})
+
+		Context("when the storage host returns an error", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusFound, nil, http.Header{
+						"Location": []string{fmt.Sprintf("%s/bucket/some-file?X-Amz-Signature=abc", storageServer.URL())},
+					}),
+				)
+
+				storageServer.AppendHandlers(
+					ghttp.RespondWith(http.StatusForbidden, "<Error>AccessDenied</Error>"),
+				)
+			})
+
+			It("returns an ErrDownloadFailed without the signature", func() {
+				_, err := client.Download(bytes.NewBuffer(nil), downloadLink)
+				Expect(err).To(MatchError(pivnet.ErrDownloadFailed{
+					ResponseCode: http.StatusForbidden,
+					URL:          fmt.Sprintf("%s/bucket/some-file", storageServer.URL()),
+				}))
+			})
+		})
+
+		Context("when the redirects do not terminate", func() {
+			BeforeEach(func() {
+				server.RouteToHandler("POST", fmt.Sprintf("%s%s", apiPrefix, downloadLink),
+					ghttp.RespondWith(http.StatusFound, nil, http.Header{
+						"Location": []string{fmt.Sprintf("%s/loop", storageServer.URL())},
+					}),
+				)
+
+				storageServer.RouteToHandler("GET", "/loop",
+					ghttp.RespondWith(http.StatusFound, nil, http.Header{
+						"Location": []string{"/loop"},
+					}),
+				)
+			})
+
+			It("returns an ErrTooManyRedirects", func() {
+				_, err := client.Download(bytes.NewBuffer(nil), downloadLink)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrTooManyRedirects{}))
+			})
+		})
+
+		Context("when there is an error copying the contents", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusOK, fileContents),
+				)
+			})
+
+			It("returns an error", func() {
+				_, err := client.Download(errWriter{}, downloadLink)
+				Expect(err).To(HaveOccurred())
+
+				Expect(err.Error()).To(ContainSubstring("error writing"))
+			})
+		})
+	})
+})
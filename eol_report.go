@@ -0,0 +1,327 @@
+package pivnet
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+// EOLMilestone is a lifecycle date a release can reach.
+type EOLMilestone string
+
+const (
+	EOLMilestoneEndOfSupport      EOLMilestone = "end_of_support"
+	EOLMilestoneEndOfGuidance     EOLMilestone = "end_of_guidance"
+	EOLMilestoneEndOfAvailability EOLMilestone = "end_of_availability"
+)
+
+var allEOLMilestones = []EOLMilestone{
+	EOLMilestoneEndOfSupport,
+	EOLMilestoneEndOfGuidance,
+	EOLMilestoneEndOfAvailability,
+}
+
+const (
+	SortByMilestone     SortField = "milestone"
+	SortByMilestoneDate SortField = "milestone_date"
+)
+
+const defaultEOLReportConcurrency = 4
+
+type EOLReportConfig struct {
+	// ProductSlugs restricts the report to the given products. Every
+	// product is walked if it is empty.
+	ProductSlugs []string
+
+	// Milestones defaults to all of them.
+	Milestones []EOLMilestone
+
+	// The report covers milestones from the day of Now, which defaults to
+	// the current time, up to and including the day Within later.
+	Now    time.Time
+	Within time.Duration
+
+	// SortBy orders the entries of each product by SortByMilestoneDate (the
+	// default), SortByVersion or SortByMilestone.
+	SortBy     SortField
+	Descending bool
+
+	// Concurrency is the number of products whose releases are listed in
+	// parallel.
+	Concurrency int
+}
+
+type EOLReport struct {
+	From     string       `json:"from" yaml:"from"`
+	To       string       `json:"to" yaml:"to"`
+	Products []EOLProduct `json:"products" yaml:"products"`
+}
+
+// EOLProduct is a product with releases that reach a milestone in the
+// report's window.
+type EOLProduct struct {
+	Slug    string     `json:"slug" yaml:"slug"`
+	Name    string     `json:"name" yaml:"name"`
+	Entries []EOLEntry `json:"entries" yaml:"entries"`
+}
+
+type EOLEntry struct {
+	ReleaseID     int          `json:"release_id" yaml:"release_id"`
+	Version       string       `json:"version" yaml:"version"`
+	Milestone     EOLMilestone `json:"milestone" yaml:"milestone"`
+	Date          string       `json:"date" yaml:"date"`
+	DaysRemaining int          `json:"days_remaining" yaml:"days_remaining"`
+}
+
+// EOLReport lists the releases of each product whose end of support,
+// guidance or availability dates fall within the config's window, grouped
+// by product. Products without such releases are left out.
+func (p ProductsService) EOLReport(config EOLReportConfig) (EOLReport, error) {
+	if config.Within <= 0 {
+		return EOLReport{}, fmt.Errorf("EOL report window must be positive")
+	}
+
+	less, err := eolEntryLess(config.SortBy)
+	if err != nil {
+		return EOLReport{}, err
+	}
+
+	milestones := config.Milestones
+	if len(milestones) == 0 {
+		milestones = allEOLMilestones
+	}
+
+	for _, milestone := range milestones {
+		if !isEOLMilestone(milestone) {
+			return EOLReport{}, fmt.Errorf("unknown EOL milestone: %s", milestone)
+		}
+	}
+
+	now := config.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	from := utcDay(now)
+	to := utcDay(now.Add(config.Within))
+
+	products, err := p.eolProducts(config.ProductSlugs)
+	if err != nil {
+		return EOLReport{}, err
+	}
+
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultEOLReportConcurrency
+	}
+
+	releases := ReleasesService{client: p.client, l: p.l}
+	grouped := make([]EOLProduct, len(products))
+
+	err = forEachConcurrently(len(products), concurrency, func(i int) error {
+		p.l.Debug("Listing releases for EOL report", logger.Data{"product": products[i].Slug})
+
+		productReleases, err := releases.ListAll(context.Background(), products[i].Slug)
+		if err != nil {
+			return err
+		}
+
+		grouped[i] = EOLProduct{
+			Slug:    products[i].Slug,
+			Name:    products[i].Name,
+			Entries: eolEntries(productReleases, milestones, now, from, to),
+		}
+		return nil
+	})
+	if err != nil {
+		return EOLReport{}, err
+	}
+
+	report := EOLReport{
+		From: from.Format(releaseDateLayout),
+		To:   to.Format(releaseDateLayout),
+	}
+
+	for _, product := range grouped {
+		if len(product.Entries) == 0 {
+			continue
+		}
+
+		entries := product.Entries
+		sort.SliceStable(entries, ordered(config.Descending, func(i, j int) bool {
+			return less(entries[i], entries[j])
+		}))
+
+		report.Products = append(report.Products, product)
+	}
+
+	sort.Slice(report.Products, func(i, j int) bool {
+		return report.Products[i].Slug < report.Products[j].Slug
+	})
+
+	return report, nil
+}
+
+func (p ProductsService) eolProducts(slugs []string) ([]Product, error) {
+	if len(slugs) == 0 {
+		return p.List()
+	}
+
+	seen := map[string]bool{}
+
+	var products []Product
+	for _, slug := range slugs {
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		product, err := p.Get(slug)
+		if err != nil {
+			return nil, err
+		}
+
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+func eolEntries(releases []Release, milestones []EOLMilestone, now time.Time, from time.Time, to time.Time) []EOLEntry {
+	var entries []EOLEntry
+	for _, release := range releases {
+		for _, milestone := range milestones {
+			date := release.milestoneDate(milestone)
+
+			t, ok := parseDate(date)
+			if !ok {
+				continue
+			}
+
+			day := utcDay(t)
+			if day.Before(from) || day.After(to) {
+				continue
+			}
+
+			entries = append(entries, EOLEntry{
+				ReleaseID:     release.ID,
+				Version:       release.Version,
+				Milestone:     milestone,
+				Date:          date,
+				DaysRemaining: int(day.Sub(utcDay(now)).Hours() / 24),
+			})
+		}
+	}
+
+	return entries
+}
+
+func isEOLMilestone(milestone EOLMilestone) bool {
+	for _, m := range allEOLMilestones {
+		if m == milestone {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Release) milestoneDate(milestone EOLMilestone) string {
+	switch milestone {
+	case EOLMilestoneEndOfSupport:
+		return r.EndOfSupportDate
+	case EOLMilestoneEndOfGuidance:
+		return r.EndOfGuidanceDate
+	case EOLMilestoneEndOfAvailability:
+		return r.EndOfAvailabilityDate
+	}
+
+	return ""
+}
+
+func eolEntryLess(field SortField) (func(a, b EOLEntry) bool, error) {
+	switch field {
+	case "", SortByMilestoneDate:
+		return func(a, b EOLEntry) bool { return a.DaysRemaining < b.DaysRemaining }, nil
+	case SortByVersion:
+		return func(a, b EOLEntry) bool { return compareReleaseVersions(a.Version, b.Version) < 0 }, nil
+	case SortByMilestone:
+		return func(a, b EOLEntry) bool { return a.Milestone < b.Milestone }, nil
+	}
+
+	return nil, fmt.Errorf("EOL reports cannot be sorted by %s", field)
+}
+
+// Write writes the report as a table, JSON or CSV. The table and CSV list
+// entries grouped by product.
+func (r EOLReport) Write(w io.Writer, format OutputFormat) error {
+	switch format {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case OutputFormatText:
+		return r.writeTable(w)
+	case OutputFormatCSV:
+		return r.writeCSV(w)
+	}
+
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+func (r EOLReport) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "PRODUCT\tVERSION\tMILESTONE\tDATE\tDAYS\n")
+	for _, product := range r.Products {
+		for _, e := range product.Entries {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", product.Slug, e.Version, e.Milestone, e.Date, e.DaysRemaining)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func (r EOLReport) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{
+		"product_slug",
+		"product_name",
+		"release_id",
+		"version",
+		"milestone",
+		"date",
+		"days_remaining",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, product := range r.Products {
+		for _, e := range product.Entries {
+			err := cw.Write([]string{
+				product.Slug,
+				product.Name,
+				strconv.Itoa(e.ReleaseID),
+				e.Version,
+				string(e.Milestone),
+				e.Date,
+				strconv.Itoa(e.DaysRemaining),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,254 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - EOL report", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		config pivnet.EOLReportConfig
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products", apiPrefix),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductsResponse{Products: []pivnet.Product{
+				{Slug: "stemcells", Name: "Stemcells"},
+				{Slug: "elastic-runtime", Name: "Elastic Runtime"},
+				{Slug: "quiet", Name: "Quiet"},
+			}}),
+		)
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/stemcells", apiPrefix),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.Product{Slug: "stemcells", Name: "Stemcells"}),
+		)
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/stemcells/releases", apiPrefix),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: []pivnet.Release{
+				{ID: 1, Version: "3000.1", EndOfSupportDate: "2017-02-01", EndOfAvailabilityDate: "2017-03-15"},
+				{ID: 2, Version: "3000.2", EndOfSupportDate: "2017-01-10"},
+				{ID: 3, Version: "3100.1", EndOfSupportDate: "2018-01-01"},
+			}}),
+		)
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/elastic-runtime/releases", apiPrefix),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: []pivnet.Release{
+				{ID: 4, Version: "1.9.0", EndOfGuidanceDate: "2017-01-05"},
+				{ID: 5, Version: "1.8.0", EndOfSupportDate: "2016-12-01"},
+			}}),
+		)
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/quiet/releases", apiPrefix),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: []pivnet.Release{
+				{ID: 6, Version: "1.0.0"},
+			}}),
+		)
+
+		config = pivnet.EOLReportConfig{
+			Now:    time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC),
+			Within: 60 * 24 * time.Hour,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("groups milestones in the window by product", func() {
+		report, err := client.Products.EOLReport(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report).To(Equal(pivnet.EOLReport{
+			From: "2017-01-01",
+			To:   "2017-03-02",
+			Products: []pivnet.EOLProduct{
+				{
+					Slug: "elastic-runtime",
+					Name: "Elastic Runtime",
+					Entries: []pivnet.EOLEntry{
+						{ReleaseID: 4, Version: "1.9.0", Milestone: pivnet.EOLMilestoneEndOfGuidance, Date: "2017-01-05", DaysRemaining: 4},
+					},
+				},
+				{
+					Slug: "stemcells",
+					Name: "Stemcells",
+					Entries: []pivnet.EOLEntry{
+						{ReleaseID: 2, Version: "3000.2", Milestone: pivnet.EOLMilestoneEndOfSupport, Date: "2017-01-10", DaysRemaining: 9},
+						{ReleaseID: 1, Version: "3000.1", Milestone: pivnet.EOLMilestoneEndOfSupport, Date: "2017-02-01", DaysRemaining: 31},
+					},
+				},
+			},
+		}))
+	})
+
+	Context("when products and milestones are restricted", func() {
+		BeforeEach(func() {
+			config.ProductSlugs = []string{"stemcells"}
+			config.Milestones = []pivnet.EOLMilestone{pivnet.EOLMilestoneEndOfAvailability}
+			config.Within = 90 * 24 * time.Hour
+		})
+
+		It("reports only those", func() {
+			report, err := client.Products.EOLReport(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Products).To(HaveLen(1))
+			Expect(report.Products[0].Entries).To(Equal([]pivnet.EOLEntry{
+				{ReleaseID: 1, Version: "3000.1", Milestone: pivnet.EOLMilestoneEndOfAvailability, Date: "2017-03-15", DaysRemaining: 73},
+			}))
+		})
+	})
+
+	Context("when a product is given more than once", func() {
+		BeforeEach(func() {
+			config.ProductSlugs = []string{"stemcells", "stemcells"}
+		})
+
+		It("reports it once", func() {
+			report, err := client.Products.EOLReport(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Products).To(HaveLen(1))
+			Expect(report.Products[0].Entries).To(HaveLen(2))
+
+			var gets int
+			for _, req := range server.ReceivedRequests() {
+				if req.URL.Path == fmt.Sprintf("%s/products/stemcells", apiPrefix) {
+					gets++
+				}
+			}
+			Expect(gets).To(Equal(1))
+		})
+	})
+
+	Context("when a milestone is unknown", func() {
+		BeforeEach(func() {
+			config.Milestones = []pivnet.EOLMilestone{pivnet.EOLMilestoneEndOfSupport, "end_of_life"}
+		})
+
+		It("returns an error without listing anything", func() {
+			_, err := client.Products.EOLReport(config)
+			Expect(err).To(MatchError("unknown EOL milestone: end_of_life"))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when sorting by version descending", func() {
+		BeforeEach(func() {
+			config.SortBy = pivnet.SortByVersion
+			config.Descending = true
+		})
+
+		It("orders the entries of each product", func() {
+			report, err := client.Products.EOLReport(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Products[1].Entries[0].Version).To(Equal("3000.2"))
+			Expect(report.Products[1].Entries[1].Version).To(Equal("3000.1"))
+		})
+	})
+
+	Context("when the sort field is not supported", func() {
+		BeforeEach(func() {
+			config.SortBy = pivnet.SortByFileType
+		})
+
+		It("returns an error", func() {
+			_, err := client.Products.EOLReport(config)
+			Expect(err).To(MatchError("EOL reports cannot be sorted by file_type"))
+		})
+	})
+
+	Context("when the window is not positive", func() {
+		BeforeEach(func() {
+			config.Within = 0
+		})
+
+		It("returns an error", func() {
+			_, err := client.Products.EOLReport(config)
+			Expect(err).To(MatchError("EOL report window must be positive"))
+		})
+	})
+
+	Context("when listing releases fails", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/quiet/releases", apiPrefix),
+				ghttp.RespondWith(http.StatusTeapot, `{"message": "foo message"}`),
+			)
+		})
+
+		It("returns the error", func() {
+			_, err := client.Products.EOLReport(config)
+			Expect(err.Error()).To(ContainSubstring("foo message"))
+		})
+	})
+
+	Describe("Write", func() {
+		var report pivnet.EOLReport
+
+		BeforeEach(func() {
+			var err error
+			report, err = client.Products.EOLReport(config)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("writes a table", func() {
+			var b bytes.Buffer
+			Expect(report.Write(&b, pivnet.OutputFormatText)).To(Succeed())
+			Expect(b.String()).To(Equal(`PRODUCT          VERSION  MILESTONE        DATE        DAYS
+elastic-runtime  1.9.0    end_of_guidance  2017-01-05  4
+stemcells        3000.2   end_of_support   2017-01-10  9
+stemcells        3000.1   end_of_support   2017-02-01  31
+`))
+		})
+
+		It("writes CSV", func() {
+			var b bytes.Buffer
+			Expect(report.Write(&b, pivnet.OutputFormatCSV)).To(Succeed())
+			Expect(b.String()).To(Equal(`product_slug,product_name,release_id,version,milestone,date,days_remaining
+elastic-runtime,Elastic Runtime,4,1.9.0,end_of_guidance,2017-01-05,4
+stemcells,Stemcells,2,3000.2,end_of_support,2017-01-10,9
+stemcells,Stemcells,1,3000.1,end_of_support,2017-02-01,31
+`))
+		})
+
+		It("writes JSON", func() {
+			var b bytes.Buffer
+			Expect(report.Write(&b, pivnet.OutputFormatJSON)).To(Succeed())
+			Expect(b.String()).To(ContainSubstring(`"days_remaining": 31`))
+		})
+
+		It("rejects other formats", func() {
+			Expect(report.Write(&bytes.Buffer{}, pivnet.OutputFormatMarkdown)).To(
+				MatchError("unsupported output format: markdown"))
+		})
+	})
+})
@@ -0,0 +1,503 @@
+package pivnet
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const (
+	archiveTarGz = "tgz"
+	archiveZip   = "zip"
+)
+
+// ExtractResult describes the contents of an extracted archive.
+type ExtractResult struct {
+	Dir string `json:"dir" yaml:"dir"`
+
+	// Files are the regular files extracted from the archive, as
+	// slash-separated paths relative to Dir.
+	Files []string `json:"files" yaml:"files"`
+
+	// MissingFiles are the product file's IncludedFiles that do not match
+	// any file in the archive, and UnlistedFiles are the files in the
+	// archive that do not match any of its IncludedFiles. An included file
+	// matches an archive file if it equals either the file's path or its
+	// base name. Both are empty when the product file advertises no
+	// IncludedFiles.
+	MissingFiles  []string `json:"missing_files,omitempty" yaml:"missing_files,omitempty"`
+	UnlistedFiles []string `json:"unlisted_files,omitempty" yaml:"unlisted_files,omitempty"`
+}
+
+// MatchesIncludedFiles reports whether the archive contents agree with the
+// product file's IncludedFiles.
+func (e ExtractResult) MatchesIncludedFiles() bool {
+	return len(e.MissingFiles) == 0 && len(e.UnlistedFiles) == 0
+}
+
+type ErrUnsafeArchivePath struct {
+	Path string `json:"path" yaml:"path"`
+}
+
+func (e ErrUnsafeArchivePath) Error() string {
+	return fmt.Sprintf("archive entry would be extracted outside the destination directory: %s", e.Path)
+}
+
+func archiveFormat(fileName string) string {
+	switch {
+	case strings.HasSuffix(fileName, ".tgz"), strings.HasSuffix(fileName, ".tar.gz"):
+		return archiveTarGz
+	case strings.HasSuffix(fileName, ".zip"):
+		return archiveZip
+	}
+
+	return ""
+}
+
+// downloadExtracted writes the product file to writer and extracts it into
+// options.ExtractDir. Gzipped tarballs are extracted as they stream; zips
+// need random access so they are staged in a temporary file first. Either
+// way, nothing is moved into options.ExtractDir until the download has been
+// verified.
+func (p ProductFilesService) downloadExtracted(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	dir := options.ExtractDir
+	options.ExtractDir = ""
+
+	switch archiveFormat(productFile.FileName()) {
+	case archiveTarGz:
+		return p.downloadUntarring(writer, productSlug, releaseID, productFile, dir, options)
+	case archiveZip:
+		return p.downloadUnzipping(writer, productSlug, releaseID, productFile, dir, options)
+	}
+
+	p.client.logger.Debug("Not extracting product file", logger.Data{
+		"productFileID": productFile.ID,
+		"fileName":      productFile.FileName(),
+	})
+
+	return p.download(writer, productSlug, releaseID, productFile, options)
+}
+
+func (p ProductFilesService) downloadUntarring(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	dir string,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	var result DownloadResult
+
+	extracted, err := p.extractStaged(dir, productFile, func(ex *extraction) error {
+		pr, pw := io.Pipe()
+		untarred := make(chan error, 1)
+
+		go func() {
+			err := ex.untar(pr)
+			if err != nil {
+				// Fails the download's next write
+				pr.CloseWithError(err)
+			} else {
+				// Consume any padding after the end of the archive
+				_, err = io.Copy(ioutil.Discard, pr)
+			}
+			untarred <- err
+		}()
+
+		var err error
+		result, err = p.download(io.MultiWriter(writer, pw), productSlug, releaseID, productFile, options)
+		pw.CloseWithError(err)
+
+		untarErr := <-untarred
+		if err == nil {
+			err = untarErr
+		}
+
+		return err
+	})
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	result.Extracted = extracted
+
+	return result, nil
+}
+
+func (p ProductFilesService) downloadUnzipping(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFile ProductFile,
+	dir string,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	staged, err := ioutil.TempFile("", "pivnet-download-")
+	if err != nil {
+		return DownloadResult{}, err
+	}
+	defer os.Remove(staged.Name())
+
+	result, err := p.download(io.MultiWriter(writer, staged), productSlug, releaseID, productFile, options)
+
+	closeErr := staged.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	result.Extracted, err = p.extractFile(staged.Name(), productFile, dir)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	return result, nil
+}
+
+// extractFile extracts the downloaded product file at filePath into dir.
+// It returns nil if the product file is not an archive.
+func (p ProductFilesService) extractFile(
+	filePath string,
+	productFile ProductFile,
+	dir string,
+) (*ExtractResult, error) {
+	format := archiveFormat(productFile.FileName())
+	if format == "" {
+		return nil, nil
+	}
+
+	return p.extractStaged(dir, productFile, func(ex *extraction) error {
+		if format == archiveZip {
+			return ex.unzip(filePath)
+		}
+
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return ex.untar(f)
+	})
+}
+
+// extractStaged runs extract against a staging directory inside dir and
+// moves what it extracted into dir only if it succeeds, so that a failed
+// download or extraction leaves the files already in dir alone.
+func (p ProductFilesService) extractStaged(
+	dir string,
+	productFile ProductFile,
+	extract func(ex *extraction) error,
+) (*ExtractResult, error) {
+	out := newExtraction(dir, p.client.logger)
+
+	err := out.mkdirAll(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	staging, err := ioutil.TempDir(dir, ".pivnet-extract-")
+	if err != nil {
+		out.remove()
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	staged := newExtraction(staging, p.client.logger)
+
+	err = extract(staged)
+	if err == nil {
+		err = out.moveFrom(staged)
+	}
+	if err != nil {
+		out.remove()
+		return nil, err
+	}
+
+	return out.result(productFile), nil
+}
+
+// extraction writes archive entries below dir and remembers what it created
+// so that a failed download can be cleaned up. Only directories and regular
+// files are extracted; links and special files are skipped.
+type extraction struct {
+	dir    string
+	logger logger.Logger
+
+	dirs    []string
+	files   []string
+	written map[string]bool
+
+	// created holds only the paths that did not exist before, so that
+	// cleaning up never removes files that were already there
+	created []string
+}
+
+func newExtraction(dir string, logger logger.Logger) *extraction {
+	return &extraction{
+		dir:     dir,
+		logger:  logger,
+		written: map[string]bool{},
+	}
+}
+
+func (e *extraction) untar(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = e.extractEntry(header.Name, header.FileInfo().Mode(), tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (e *extraction) unzip(filePath string) error {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		err = e.extractZipEntry(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *extraction) extractZipEntry(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return e.extractEntry(f.Name, f.Mode(), rc)
+}
+
+func (e *extraction) extractEntry(name string, mode os.FileMode, contents io.Reader) error {
+	dest, rel, err := archiveEntryPath(e.dir, name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case rel == ".":
+		return nil
+	case mode.IsDir():
+		e.dirs = append(e.dirs, rel)
+		return e.mkdirAll(dest)
+	case mode.IsRegular():
+		return e.writeFile(dest, rel, mode, contents)
+	}
+
+	e.logger.Debug("Skipping archive entry", logger.Data{
+		"name": name,
+		"mode": mode.String(),
+	})
+
+	return nil
+}
+
+func (e *extraction) writeFile(dest string, rel string, mode os.FileMode, contents io.Reader) error {
+	err := e.mkdirAll(filepath.Dir(dest))
+	if err != nil {
+		return err
+	}
+
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+
+	existed := exists(dest)
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	e.recordFile(dest, rel, existed)
+
+	_, err = io.Copy(f, contents)
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// mkdirAll creates dir and any missing parents, remembering the ones it
+// created.
+func (e *extraction) mkdirAll(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+
+	parent := filepath.Dir(dir)
+	if parent != dir {
+		err := e.mkdirAll(parent)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := os.Mkdir(dir, 0755)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	if err == nil {
+		e.created = append(e.created, dir)
+	}
+
+	return nil
+}
+
+// moveFrom moves the directories and files extracted by staged into e's
+// directory, replacing files of the same name.
+func (e *extraction) moveFrom(staged *extraction) error {
+	for _, rel := range staged.dirs {
+		err := e.mkdirAll(filepath.Join(e.dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range staged.files {
+		dest := filepath.Join(e.dir, filepath.FromSlash(rel))
+
+		err := e.mkdirAll(filepath.Dir(dest))
+		if err != nil {
+			return err
+		}
+
+		existed := exists(dest)
+
+		err = os.Rename(filepath.Join(staged.dir, filepath.FromSlash(rel)), dest)
+		if err != nil {
+			return err
+		}
+		e.recordFile(dest, rel, existed)
+	}
+
+	return nil
+}
+
+func (e *extraction) recordFile(dest string, rel string, existed bool) {
+	if !existed {
+		e.created = append(e.created, dest)
+	}
+
+	if !e.written[rel] {
+		e.written[rel] = true
+		e.files = append(e.files, rel)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// remove deletes everything the extraction created, most recent first.
+func (e *extraction) remove() {
+	for i := len(e.created) - 1; i >= 0; i-- {
+		os.Remove(e.created[i])
+	}
+}
+
+func (e *extraction) result(productFile ProductFile) *ExtractResult {
+	sort.Strings(e.files)
+
+	result := &ExtractResult{
+		Dir:   e.dir,
+		Files: e.files,
+	}
+
+	if len(productFile.IncludedFiles) == 0 {
+		return result
+	}
+
+	matched := map[string]bool{}
+	for _, included := range productFile.IncludedFiles {
+		found := false
+		for _, file := range e.files {
+			if file == included || path.Base(file) == included {
+				found = true
+				matched[file] = true
+			}
+		}
+
+		if !found {
+			result.MissingFiles = append(result.MissingFiles, included)
+		}
+	}
+
+	for _, file := range e.files {
+		if !matched[file] {
+			result.UnlistedFiles = append(result.UnlistedFiles, file)
+		}
+	}
+
+	if !result.MatchesIncludedFiles() {
+		e.logger.Info("Archive contents differ from included files", logger.Data{
+			"productFileID": productFile.ID,
+			"missingFiles":  result.MissingFiles,
+			"unlistedFiles": result.UnlistedFiles,
+		})
+	}
+
+	return result
+}
+
+// archiveEntryPath returns where an archive entry is extracted to below dir,
+// and its cleaned slash-separated path relative to dir. Entries that would
+// land outside dir are rejected rather than cleaned into it.
+func archiveEntryPath(dir string, name string) (string, string, error) {
+	rel := path.Clean(strings.Replace(name, `\`, "/", -1))
+
+	if path.IsAbs(rel) ||
+		rel == ".." ||
+		strings.HasPrefix(rel, "../") ||
+		filepath.VolumeName(filepath.FromSlash(rel)) != "" {
+		return "", "", ErrUnsafeArchivePath{Path: name}
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(rel)), rel, nil
+}
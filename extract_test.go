@@ -0,0 +1,362 @@
+package pivnet_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+type archiveEntry struct {
+	name     string
+	contents string
+}
+
+func tarGz(entries ...archiveEntry) []byte {
+	var b bytes.Buffer
+
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+
+	for _, entry := range entries {
+		Expect(tw.WriteHeader(&tar.Header{
+			Name:     entry.name,
+			Mode:     0644,
+			Size:     int64(len(entry.contents)),
+			Typeflag: tar.TypeReg,
+		})).To(Succeed())
+
+		_, err := tw.Write([]byte(entry.contents))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+
+	return b.Bytes()
+}
+
+func zipArchive(entries ...archiveEntry) []byte {
+	var b bytes.Buffer
+
+	zw := zip.NewWriter(&b)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = w.Write([]byte(entry.contents))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	Expect(zw.Close()).To(Succeed())
+
+	return b.Bytes()
+}
+
+var _ = Describe("PivnetClient - extract", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		extractDir string
+
+		releaseID     int
+		productFileID int
+		fileContents  []byte
+		productFile   pivnet.ProductFile
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		var err error
+		extractDir, err = ioutil.TempDir("", "extract")
+		Expect(err).NotTo(HaveOccurred())
+
+		releaseID = 12
+		productFileID = 1234
+		fileContents = tarGz(
+			archiveEntry{name: "bin/tool", contents: "some tool"},
+			archiveEntry{name: "README.md", contents: "some readme"},
+		)
+
+		productFile = pivnet.ProductFile{
+			ID:           productFileID,
+			AWSObjectKey: "product-files/p/file.tgz",
+			Links: &pivnet.Links{
+				Download: map[string]string{
+					"href": fmt.Sprintf("/products/%s/releases/%d/product_files/%d/download", productSlug, releaseID, productFileID),
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(extractDir)
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, productFileID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: productFile}),
+		)
+
+		server.RouteToHandler("POST",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d/download", apiPrefix, productSlug, releaseID, productFileID),
+			func(w http.ResponseWriter, req *http.Request) {
+				w.Write(fileContents)
+			},
+		)
+	})
+
+	download := func() (pivnet.DownloadResult, []byte, error) {
+		writer := bytes.NewBuffer(nil)
+		result, err := client.ProductFiles.DownloadForReleaseWithOptions(
+			writer,
+			productSlug,
+			releaseID,
+			productFileID,
+			pivnet.DownloadOptions{ExtractDir: extractDir},
+		)
+		return result, writer.Bytes(), err
+	}
+
+	readExtracted := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join(extractDir, name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("writes the archive and extracts it", func() {
+		result, contents, err := download()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents).To(Equal(fileContents))
+
+		Expect(result.Extracted).NotTo(BeNil())
+		Expect(result.Extracted.Dir).To(Equal(extractDir))
+		Expect(result.Extracted.Files).To(Equal([]string{"README.md", "bin/tool"}))
+		Expect(result.Extracted.MatchesIncludedFiles()).To(BeTrue())
+
+		Expect(readExtracted("bin/tool")).To(Equal("some tool"))
+		Expect(readExtracted("README.md")).To(Equal("some readme"))
+	})
+
+	Context("when the product file advertises included files", func() {
+		BeforeEach(func() {
+			productFile.IncludedFiles = []string{"tool", "LICENSE"}
+		})
+
+		It("reports the discrepancies", func() {
+			result, _, err := download()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Extracted.MissingFiles).To(Equal([]string{"LICENSE"}))
+			Expect(result.Extracted.UnlistedFiles).To(Equal([]string{"README.md"}))
+			Expect(result.Extracted.MatchesIncludedFiles()).To(BeFalse())
+		})
+	})
+
+	Context("when the product file is a zip", func() {
+		BeforeEach(func() {
+			productFile.AWSObjectKey = "product-files/p/file.zip"
+			fileContents = zipArchive(
+				archiveEntry{name: "docs/", contents: ""},
+				archiveEntry{name: "docs/guide.txt", contents: "some guide"},
+			)
+		})
+
+		It("extracts it", func() {
+			result, contents, err := download()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents).To(Equal(fileContents))
+
+			Expect(result.Extracted.Files).To(Equal([]string{"docs/guide.txt"}))
+			Expect(readExtracted("docs/guide.txt")).To(Equal("some guide"))
+		})
+	})
+
+	Context("when the product file is not an archive", func() {
+		BeforeEach(func() {
+			productFile.AWSObjectKey = "product-files/p/notes.pdf"
+			fileContents = []byte("some notes")
+		})
+
+		It("downloads it without extracting", func() {
+			result, contents, err := download()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents).To(Equal(fileContents))
+			Expect(result.Extracted).To(BeNil())
+
+			entries, err := ioutil.ReadDir(extractDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Context("when an archive entry escapes the destination", func() {
+		BeforeEach(func() {
+			fileContents = tarGz(
+				archiveEntry{name: "inside/file", contents: "fine"},
+				archiveEntry{name: "../escaped", contents: "not fine"},
+			)
+		})
+
+		It("returns an ErrUnsafeArchivePath and removes what it extracted", func() {
+			_, _, err := download()
+			Expect(err).To(MatchError(pivnet.ErrUnsafeArchivePath{Path: "../escaped"}))
+
+			_, err = os.Stat(filepath.Join(extractDir, "..", "escaped"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+
+			entries, err := ioutil.ReadDir(extractDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Context("when the downloaded contents do not match the checksum", func() {
+		BeforeEach(func() {
+			productFile.SHA256 = "abcdef"
+		})
+
+		It("removes what it extracted", func() {
+			_, _, err := download()
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrChecksumMismatch{}))
+
+			entries, err := ioutil.ReadDir(extractDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Context("when the extract directory already has files", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(filepath.Join(extractDir, "README.md"), []byte("my readme"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(extractDir, "notes.txt"), []byte("my notes"), 0644)).To(Succeed())
+		})
+
+		It("replaces those in the archive and leaves no staging directory behind", func() {
+			_, _, err := download()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(readExtracted("README.md")).To(Equal("some readme"))
+			Expect(readExtracted("notes.txt")).To(Equal("my notes"))
+
+			entries, err := ioutil.ReadDir(extractDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			Expect(names).To(ConsistOf("README.md", "bin", "notes.txt"))
+		})
+
+		Context("when the download fails verification", func() {
+			BeforeEach(func() {
+				productFile.SHA256 = "abcdef"
+			})
+
+			It("leaves them untouched", func() {
+				_, _, err := download()
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrChecksumMismatch{}))
+
+				Expect(readExtracted("README.md")).To(Equal("my readme"))
+				Expect(readExtracted("notes.txt")).To(Equal("my notes"))
+
+				_, err = os.Stat(filepath.Join(extractDir, "bin"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when extraction fails part way", func() {
+			BeforeEach(func() {
+				fileContents = tarGz(
+					archiveEntry{name: "README.md", contents: "some readme"},
+					archiveEntry{name: "../escaped", contents: "not fine"},
+				)
+			})
+
+			It("leaves them untouched", func() {
+				_, _, err := download()
+				Expect(err).To(MatchError(pivnet.ErrUnsafeArchivePath{Path: "../escaped"}))
+
+				Expect(readExtracted("README.md")).To(Equal("my readme"))
+			})
+		})
+	})
+
+	Context("when downloading a release into a directory", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "extract-dest")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{
+					ProductFiles: []pivnet.ProductFile{productFile},
+				}),
+			)
+
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/releases/%d/file_groups", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{}),
+			)
+		})
+
+		It("places the archive and extracts it", func() {
+			files, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+				ProductSlug: productSlug,
+				ReleaseID:   releaseID,
+				Dir:         dir,
+				Options:     pivnet.DownloadOptions{ExtractDir: extractDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(files[0].Result.Extracted.Files).To(Equal([]string{"README.md", "bin/tool"}))
+			Expect(readExtracted("bin/tool")).To(Equal("some tool"))
+
+			_, err = os.Stat(filepath.Join(dir, "file.tgz"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,313 @@
+package pivnet_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+)
+
+// fakePivnet is an in-memory stand-in for the parts of the Pivnet API that
+// manage releases and what is attached to them.
+type fakePivnet struct {
+	mu sync.Mutex
+
+	nextID       int
+	releases     map[string][]pivnet.Release
+	productFiles map[string][]pivnet.ProductFile
+	fileGroups   map[string][]pivnet.FileGroup
+	userGroups   []pivnet.UserGroup
+
+	// attached maps release IDs to the kinds of things attached to them and
+	// their IDs, e.g. "product_file" to product file IDs.
+	attached map[int]map[string][]int
+
+	writes []string
+}
+
+func newFakePivnet() *fakePivnet {
+	return &fakePivnet{
+		nextID:       100,
+		releases:     map[string][]pivnet.Release{},
+		productFiles: map[string][]pivnet.ProductFile{},
+		fileGroups:   map[string][]pivnet.FileGroup{},
+		attached:     map[int]map[string][]int{},
+	}
+}
+
+// routeFrom sends every request the server receives to the fake.
+func (f *fakePivnet) routeFrom(server *ghttp.Server) {
+	for _, method := range []string{"GET", "POST", "PATCH"} {
+		server.RouteToHandler(method, regexp.MustCompile(`.*`), f.ServeHTTP)
+	}
+}
+
+func (f *fakePivnet) addRelease(slug string, release pivnet.Release) pivnet.Release {
+	f.nextID++
+	release.ID = f.nextID
+	f.releases[slug] = append(f.releases[slug], release)
+	f.attached[release.ID] = map[string][]int{}
+	return release
+}
+
+func (f *fakePivnet) addProductFile(slug string, pf pivnet.ProductFile) pivnet.ProductFile {
+	f.nextID++
+	pf.ID = f.nextID
+	f.productFiles[slug] = append(f.productFiles[slug], pf)
+	return pf
+}
+
+func (f *fakePivnet) attach(releaseID int, kind string, id int) {
+	f.attached[releaseID][kind] = append(f.attached[releaseID][kind], id)
+}
+
+func (f *fakePivnet) release(slug string, id int) *pivnet.Release {
+	for i := range f.releases[slug] {
+		if f.releases[slug][i].ID == id {
+			return &f.releases[slug][i]
+		}
+	}
+	return nil
+}
+
+func (f *fakePivnet) anyRelease(id int) (pivnet.Release, string) {
+	for slug := range f.releases {
+		if r := f.release(slug, id); r != nil {
+			return *r, slug
+		}
+	}
+	return pivnet.Release{}, ""
+}
+
+func (f *fakePivnet) writeCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.writes)
+}
+
+var fakePivnetPath = regexp.MustCompile(`^/api/v2/products/([^/]+)/(releases|product_files|file_groups)(?:/(\d+))?(?:/([a-z_]+))?(?:/(\d+))?$`)
+
+func (f *fakePivnet) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	defer GinkgoRecover()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	respond := func(status int, body interface{}) {
+		w.WriteHeader(status)
+		if body != nil {
+			Expect(json.NewEncoder(w).Encode(body)).To(Succeed())
+		}
+	}
+
+	var body map[string]map[string]interface{}
+	if req.Method != "GET" {
+		f.writes = append(f.writes, req.Method+" "+req.URL.Path)
+		Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+	}
+
+	if req.URL.Path == "/api/v2/user_groups" {
+		respond(http.StatusOK, pivnet.UserGroupsResponse{UserGroups: f.userGroups})
+		return
+	}
+
+	m := fakePivnetPath.FindStringSubmatch(req.URL.Path)
+	if m == nil {
+		respond(http.StatusNotFound, map[string]string{"message": "not found"})
+		return
+	}
+
+	slug, collection, sub := m[1], m[2], m[4]
+	id, _ := strconv.Atoi(m[3])
+
+	switch {
+	case collection == "releases" && id == 0 && req.Method == "GET":
+		respond(http.StatusOK, pivnet.ReleasesResponse{Releases: f.releases[slug]})
+
+	case collection == "releases" && id == 0 && req.Method == "POST":
+		var release pivnet.Release
+		b, _ := json.Marshal(body["release"])
+		Expect(json.Unmarshal(b, &release)).To(Succeed())
+		respond(http.StatusCreated, pivnet.CreateReleaseResponse{Release: f.addRelease(slug, release)})
+
+	case collection == "releases" && sub == "" && req.Method == "GET":
+		release := f.release(slug, id)
+		if release == nil {
+			respond(http.StatusNotFound, map[string]string{"message": "release not found"})
+			return
+		}
+		respond(http.StatusOK, *release)
+
+	case collection == "releases" && sub == "" && req.Method == "PATCH":
+		release := f.release(slug, id)
+		applyPatch(release, body["release"])
+		respond(http.StatusOK, pivnet.CreateReleaseResponse{Release: *release})
+
+	case collection == "releases" && sub == "product_files" && m[5] != "" && req.Method == "GET":
+		productFileID, _ := strconv.Atoi(m[5])
+		for _, pf := range f.productFiles[slug] {
+			if pf.ID == productFileID {
+				respond(http.StatusOK, pivnet.ProductFileResponse{ProductFile: pf})
+				return
+			}
+		}
+		respond(http.StatusNotFound, map[string]string{"message": "not found"})
+
+	case collection == "releases" && req.Method == "GET":
+		f.respondWithAttached(respond, slug, id, sub)
+
+	case collection == "releases" && req.Method == "PATCH":
+		parts := strings.SplitN(sub, "_", 2)
+		kind := parts[1]
+
+		var attachedID float64
+		if v, ok := body[kind]["id"]; ok {
+			attachedID = v.(float64)
+		} else {
+			attachedID = body[kind]["release_id"].(float64)
+		}
+
+		if parts[0] == "add" {
+			f.attach(id, kind, int(attachedID))
+		} else {
+			var kept []int
+			for _, a := range f.attached[id][kind] {
+				if a != int(attachedID) {
+					kept = append(kept, a)
+				}
+			}
+			f.attached[id][kind] = kept
+		}
+		respond(http.StatusNoContent, nil)
+
+	case collection == "product_files" && id == 0 && req.Method == "GET":
+		respond(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: f.productFiles[slug]})
+
+	case collection == "product_files" && id == 0 && req.Method == "POST":
+		var pf pivnet.ProductFile
+		b, _ := json.Marshal(body["product_file"])
+		Expect(json.Unmarshal(b, &pf)).To(Succeed())
+		respond(http.StatusCreated, pivnet.ProductFileResponse{ProductFile: f.addProductFile(slug, pf)})
+
+	case collection == "product_files" && req.Method == "GET":
+		for _, pf := range f.productFiles[slug] {
+			if pf.ID == id {
+				respond(http.StatusOK, pivnet.ProductFileResponse{ProductFile: pf})
+				return
+			}
+		}
+		respond(http.StatusNotFound, map[string]string{"message": "not found"})
+
+	case collection == "product_files" && req.Method == "PATCH":
+		for i := range f.productFiles[slug] {
+			if f.productFiles[slug][i].ID == id {
+				applyPatch(&f.productFiles[slug][i], body["product_file"])
+				respond(http.StatusOK, pivnet.ProductFileResponse{ProductFile: f.productFiles[slug][i]})
+				return
+			}
+		}
+		respond(http.StatusNotFound, map[string]string{"message": "not found"})
+
+	case collection == "file_groups" && req.Method == "GET":
+		respond(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: f.fileGroups[slug]})
+
+	default:
+		respond(http.StatusNotFound, map[string]string{"message": "not found"})
+	}
+}
+
+func (f *fakePivnet) respondWithAttached(respond func(int, interface{}), slug string, releaseID int, sub string) {
+	ids := f.attached[releaseID]
+
+	switch sub {
+	case "product_files":
+		var productFiles []pivnet.ProductFile
+		for _, id := range ids["product_file"] {
+			for _, pf := range f.productFiles[slug] {
+				if pf.ID == id {
+					productFiles = append(productFiles, pf)
+				}
+			}
+		}
+		respond(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles})
+
+	case "file_groups":
+		var fileGroups []pivnet.FileGroup
+		for _, id := range ids["file_group"] {
+			for _, g := range f.fileGroups[slug] {
+				if g.ID == id {
+					fileGroups = append(fileGroups, g)
+				}
+			}
+		}
+		respond(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: fileGroups})
+
+	case "user_groups":
+		var userGroups []pivnet.UserGroup
+		for _, id := range ids["user_group"] {
+			for _, g := range f.userGroups {
+				if g.ID == id {
+					userGroups = append(userGroups, g)
+				}
+			}
+		}
+		respond(http.StatusOK, pivnet.UserGroupsResponse{UserGroups: userGroups})
+
+	case "dependencies":
+		var dependencies []pivnet.ReleaseDependency
+		for _, id := range ids["dependency"] {
+			release, dependencySlug := f.anyRelease(id)
+			dependencies = append(dependencies, pivnet.ReleaseDependency{Release: pivnet.DependentRelease{
+				ID:      id,
+				Version: release.Version,
+				Product: pivnet.Product{Slug: dependencySlug},
+			}})
+		}
+		respond(http.StatusOK, pivnet.ReleaseDependenciesResponse{ReleaseDependencies: dependencies})
+
+	case "upgrade_paths":
+		var upgradePaths []pivnet.ReleaseUpgradePath
+		for _, id := range ids["upgrade_path"] {
+			upgradePaths = append(upgradePaths, pivnet.ReleaseUpgradePath{Release: pivnet.UpgradePathRelease{
+				ID:      id,
+				Version: f.release(slug, id).Version,
+			}})
+		}
+		respond(http.StatusOK, pivnet.ReleaseUpgradePathsResponse{ReleaseUpgradePaths: upgradePaths})
+
+	default:
+		respond(http.StatusNotFound, map[string]string{"message": "not found"})
+	}
+}
+
+// applyPatch sets the fields of target named in fields, as Pivnet does for a
+// PATCH: null clears a field and false is kept rather than ignored.
+func applyPatch(target interface{}, fields map[string]interface{}) {
+	b, err := json.Marshal(target)
+	Expect(err).NotTo(HaveOccurred())
+
+	current := map[string]interface{}{}
+	Expect(json.Unmarshal(b, &current)).To(Succeed())
+
+	for k, v := range fields {
+		if v == nil {
+			delete(current, k)
+		} else {
+			current[k] = v
+		}
+	}
+
+	b, err = json.Marshal(current)
+	Expect(err).NotTo(HaveOccurred())
+
+	reflect.ValueOf(target).Elem().Set(reflect.Zero(reflect.TypeOf(target).Elem()))
+	Expect(json.Unmarshal(b, target)).To(Succeed())
+}
@@ -0,0 +1,70 @@
+//go:build !windows
+// +build !windows
+
+package pivnet
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive advisory lock on path, creating it if needed,
+// and blocks until the lock is acquired. The lock is released by the
+// returned function or when the process exits.
+func lockFile(path string) (func(), error) {
+	return flockFile(path, syscall.LOCK_EX)
+}
+
+// tryLockFile is like lockFile but returns ok false instead of blocking
+// when the lock is held elsewhere.
+func tryLockFile(path string) (unlock func(), ok bool, err error) {
+	unlock, err = flockFile(path, syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return unlock, true, nil
+}
+
+// flockFile locks the file at path. Lock files may be removed by whoever
+// holds their lock, so the lock is taken again if the file it was taken on
+// is no longer the one at path.
+func flockFile(path string, how int) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		err = syscall.Flock(int(f.Fd()), how)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		locked, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		current, err := os.Stat(path)
+		if err != nil && !os.IsNotExist(err) {
+			f.Close()
+			return nil, err
+		}
+
+		if err != nil || !os.SameFile(locked, current) {
+			f.Close()
+			continue
+		}
+
+		return func() {
+			syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+			f.Close()
+		}, nil
+	}
+}
@@ -0,0 +1,42 @@
+package pivnet
+
+import (
+	"os"
+	"time"
+)
+
+const lockFilePollInterval = 100 * time.Millisecond
+
+// lockFile takes an exclusive lock on path and blocks until the lock is
+// acquired. Without flock, the lock is the existence of path itself, so a
+// process that dies while holding it leaves the file behind to be removed
+// by hand.
+func lockFile(path string) (func(), error) {
+	for {
+		unlock, ok, err := tryLockFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return unlock, nil
+		}
+
+		time.Sleep(lockFilePollInterval)
+	}
+}
+
+func tryLockFile(path string) (unlock func(), ok bool, err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if os.IsExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	f.Close()
+
+	return func() {
+		os.Remove(path)
+	}, true, nil
+}
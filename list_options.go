@@ -0,0 +1,368 @@
+package pivnet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortField names the field a list is sorted by.
+type SortField string
+
+const (
+	SortByID          SortField = "id"
+	SortByName        SortField = "name"
+	SortByVersion     SortField = "version"
+	SortByReleaseDate SortField = "release_date"
+	SortByUpdatedAt   SortField = "updated_at"
+	SortByFileType    SortField = "file_type"
+)
+
+// Pivnet does not document query parameters for filtering, sorting or
+// limiting any of the list endpoints, so list options are applied to the
+// full list after it is fetched. Releases and product files are fetched
+// across every page via ListAll.
+
+// ListOptions are the sorting and limit options common to every list.
+type ListOptions struct {
+	// SortBy is the field to sort by. Lists are left in the order the
+	// server returns them if it is empty.
+	SortBy     SortField
+	Descending bool
+
+	// Limit, if positive, is the maximum number of items returned, counted
+	// after filtering and sorting.
+	Limit int
+}
+
+type ReleaseListOptions struct {
+	ListOptions
+
+	ReleaseTypes   []ReleaseType
+	Availabilities []ReleaseAvailability
+
+	// ReleasedAfter and ReleasedBefore restrict releases to those with a
+	// release date in the range, inclusive. Zero times are unbounded.
+	ReleasedAfter  time.Time
+	ReleasedBefore time.Time
+
+	// UpdatedAfter and UpdatedBefore restrict releases to those last updated
+	// in the range, inclusive. Zero times are unbounded.
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+}
+
+type ProductFileListOptions struct {
+	ListOptions
+
+	FileTypes []string
+
+	// Platforms restricts product files to those for at least one of the
+	// given platforms.
+	Platforms []string
+}
+
+type FileGroupListOptions struct {
+	ListOptions
+
+	// NameContains restricts file groups to those whose names contain it,
+	// ignoring case.
+	NameContains string
+}
+
+type UserGroupListOptions struct {
+	ListOptions
+
+	// NameContains restricts user groups to those whose names contain it,
+	// ignoring case.
+	NameContains string
+}
+
+// ListWithOptions lists the releases of a product, filtered, sorted and
+// limited by options. Releases with a missing or unparseable date are
+// excluded by any range on that date.
+func (r ReleasesService) ListWithOptions(
+	ctx context.Context,
+	productSlug string,
+	options ReleaseListOptions,
+) ([]Release, error) {
+	less, err := releaseLess(options.SortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := r.ListAll(ctx, productSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []Release
+	for _, release := range releases {
+		if !releaseMatchesFilters(release, options.ReleaseTypes, options.Availabilities) {
+			continue
+		}
+
+		if !inDateRange(release.ReleaseDate, options.ReleasedAfter, options.ReleasedBefore) ||
+			!inDateRange(release.UpdatedAt, options.UpdatedAfter, options.UpdatedBefore) {
+			continue
+		}
+
+		filtered = append(filtered, release)
+	}
+
+	if less != nil {
+		sort.SliceStable(filtered, ordered(options.Descending, func(i, j int) bool {
+			return less(filtered[i], filtered[j])
+		}))
+	}
+
+	return filtered[:limit(len(filtered), options.Limit)], nil
+}
+
+// ListWithOptions lists the product files of a product, filtered, sorted and
+// limited by options.
+func (p ProductFilesService) ListWithOptions(
+	ctx context.Context,
+	productSlug string,
+	options ProductFileListOptions,
+) ([]ProductFile, error) {
+	less, err := productFileLess(options.SortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	productFiles, err := p.ListAll(ctx, productSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []ProductFile
+	for _, productFile := range productFiles {
+		if !matchesFileTypesAndPlatforms(productFile, options.FileTypes, options.Platforms) {
+			continue
+		}
+
+		filtered = append(filtered, productFile)
+	}
+
+	if less != nil {
+		sort.SliceStable(filtered, ordered(options.Descending, func(i, j int) bool {
+			return less(filtered[i], filtered[j])
+		}))
+	}
+
+	return filtered[:limit(len(filtered), options.Limit)], nil
+}
+
+// ListWithOptions lists the file groups of a product, filtered, sorted and
+// limited by options.
+func (f FileGroupsService) ListWithOptions(productSlug string, options FileGroupListOptions) ([]FileGroup, error) {
+	less, err := namedLess(options.SortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	fileGroups, err := f.List(productSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []FileGroup
+	for _, fileGroup := range fileGroups {
+		if containsIgnoringCase(fileGroup.Name, options.NameContains) {
+			filtered = append(filtered, fileGroup)
+		}
+	}
+
+	if less != nil {
+		sort.SliceStable(filtered, ordered(options.Descending, func(i, j int) bool {
+			return less(filtered[i].ID, filtered[i].Name, filtered[j].ID, filtered[j].Name)
+		}))
+	}
+
+	return filtered[:limit(len(filtered), options.Limit)], nil
+}
+
+// ListWithOptions lists user groups, filtered, sorted and limited by options.
+func (u UserGroupsService) ListWithOptions(options UserGroupListOptions) ([]UserGroup, error) {
+	less, err := namedLess(options.SortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	userGroups, err := u.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []UserGroup
+	for _, userGroup := range userGroups {
+		if containsIgnoringCase(userGroup.Name, options.NameContains) {
+			filtered = append(filtered, userGroup)
+		}
+	}
+
+	if less != nil {
+		sort.SliceStable(filtered, ordered(options.Descending, func(i, j int) bool {
+			return less(filtered[i].ID, filtered[i].Name, filtered[j].ID, filtered[j].Name)
+		}))
+	}
+
+	return filtered[:limit(len(filtered), options.Limit)], nil
+}
+
+func releaseLess(field SortField) (func(a, b Release) bool, error) {
+	switch field {
+	case "":
+		return nil, nil
+	case SortByID:
+		return func(a, b Release) bool { return a.ID < b.ID }, nil
+	case SortByVersion:
+		return func(a, b Release) bool { return compareReleaseVersions(a.Version, b.Version) < 0 }, nil
+	case SortByReleaseDate:
+		return func(a, b Release) bool { return compareDates(a.ReleaseDate, b.ReleaseDate) < 0 }, nil
+	case SortByUpdatedAt:
+		return func(a, b Release) bool { return compareDates(a.UpdatedAt, b.UpdatedAt) < 0 }, nil
+	}
+
+	return nil, fmt.Errorf("releases cannot be sorted by %s", field)
+}
+
+func productFileLess(field SortField) (func(a, b ProductFile) bool, error) {
+	switch field {
+	case "":
+		return nil, nil
+	case SortByID:
+		return func(a, b ProductFile) bool { return a.ID < b.ID }, nil
+	case SortByName:
+		return func(a, b ProductFile) bool { return a.Name < b.Name }, nil
+	case SortByFileType:
+		return func(a, b ProductFile) bool { return a.FileType < b.FileType }, nil
+	case SortByReleaseDate:
+		return func(a, b ProductFile) bool { return compareDates(a.ReleasedAt, b.ReleasedAt) < 0 }, nil
+	}
+
+	return nil, fmt.Errorf("product files cannot be sorted by %s", field)
+}
+
+// namedLess orders items that only have an ID and a name, such as file
+// groups and user groups.
+func namedLess(field SortField) (func(aID int, aName string, bID int, bName string) bool, error) {
+	switch field {
+	case "":
+		return nil, nil
+	case SortByID:
+		return func(aID int, _ string, bID int, _ string) bool { return aID < bID }, nil
+	case SortByName:
+		return func(_ int, aName string, _ int, bName string) bool { return aName < bName }, nil
+	}
+
+	return nil, fmt.Errorf("groups cannot be sorted by %s", field)
+}
+
+// ordered reverses less if descending.
+func ordered(descending bool, less func(i, j int) bool) func(i, j int) bool {
+	if descending {
+		return func(i, j int) bool { return less(j, i) }
+	}
+
+	return less
+}
+
+func limit(n int, max int) int {
+	if max > 0 && max < n {
+		return max
+	}
+
+	return n
+}
+
+// parseDate parses the dates Pivnet returns, which are either plain dates
+// such as release dates or RFC 3339 timestamps.
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func inDateRange(date string, after time.Time, before time.Time) bool {
+	if after.IsZero() && before.IsZero() {
+		return true
+	}
+
+	t, ok := parseDate(date)
+	if !ok {
+		return false
+	}
+
+	return (after.IsZero() || !t.Before(after)) && (before.IsZero() || !t.After(before))
+}
+
+// compareDates orders missing or unparseable dates before all others.
+func compareDates(a string, b string) int {
+	at, aOK := parseDate(a)
+	bt, bOK := parseDate(b)
+
+	switch {
+	case aOK && bOK:
+		switch {
+		case at.Before(bt):
+			return -1
+		case at.After(bt):
+			return 1
+		}
+		return 0
+	case aOK:
+		return 1
+	case bOK:
+		return -1
+	}
+
+	return 0
+}
+
+// matchesFileTypesAndPlatforms reports whether the product file is of one of
+// the file types and supports one of the platforms, ignoring case. Empty
+// file types or platforms match every product file.
+func matchesFileTypesAndPlatforms(productFile ProductFile, fileTypes []string, platforms []string) bool {
+	if len(fileTypes) > 0 && !containsFold(fileTypes, productFile.FileType) {
+		return false
+	}
+
+	if len(platforms) > 0 && !anyContainsFold(platforms, productFile.Platforms) {
+		return false
+	}
+
+	return true
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func anyContainsFold(values []string, candidates []string) bool {
+	for _, c := range candidates {
+		if containsFold(values, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsIgnoringCase(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
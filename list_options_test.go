@@ -0,0 +1,238 @@
+package pivnet_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - list options", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Releases.ListWithOptions", func() {
+		var options pivnet.ReleaseListOptions
+
+		BeforeEach(func() {
+			options = pivnet.ReleaseListOptions{}
+
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: []pivnet.Release{
+					{ID: 1, Version: "1.10.0", ReleaseType: "Major Release", Availability: "All Users", ReleaseDate: "2016-01-01", UpdatedAt: "2016-02-01T00:00:00Z"},
+					{ID: 2, Version: "1.9.0", ReleaseType: "Minor Release", Availability: "Admins Only", ReleaseDate: "2015-06-01", UpdatedAt: "2017-01-01T00:00:00Z"},
+					{ID: 3, Version: "2.0.0", ReleaseType: "Major Release", Availability: "All Users", ReleaseDate: "2017-01-01", UpdatedAt: "2017-01-02T00:00:00Z"},
+					{ID: 4, Version: "2.1.0", ReleaseType: "Minor Release", Availability: "All Users"},
+				}}),
+			)
+		})
+
+		ids := func(releases []pivnet.Release) []int {
+			var ids []int
+			for _, release := range releases {
+				ids = append(ids, release.ID)
+			}
+			return ids
+		}
+
+		It("returns every release in server order without options", func() {
+			releases, err := client.Releases.ListWithOptions(context.Background(), productSlug, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(releases)).To(Equal([]int{1, 2, 3, 4}))
+		})
+
+		It("filters by release type and availability", func() {
+			options.ReleaseTypes = []pivnet.ReleaseType{"Major Release"}
+			options.Availabilities = []pivnet.ReleaseAvailability{"All Users"}
+
+			releases, err := client.Releases.ListWithOptions(context.Background(), productSlug, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(releases)).To(Equal([]int{1, 3}))
+		})
+
+		It("filters by release date, excluding releases without one", func() {
+			options.ReleasedAfter = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			releases, err := client.Releases.ListWithOptions(context.Background(), productSlug, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(releases)).To(Equal([]int{1, 3}))
+		})
+
+		It("filters by update time", func() {
+			options.UpdatedBefore = time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC)
+
+			releases, err := client.Releases.ListWithOptions(context.Background(), productSlug, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(releases)).To(Equal([]int{1}))
+		})
+
+		It("sorts by semantic version and limits", func() {
+			options.SortBy = pivnet.SortByVersion
+			options.Descending = true
+			options.Limit = 3
+
+			releases, err := client.Releases.ListWithOptions(context.Background(), productSlug, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(releases)).To(Equal([]int{4, 3, 1}))
+		})
+
+		It("sorts by release date", func() {
+			options.SortBy = pivnet.SortByReleaseDate
+
+			releases, err := client.Releases.ListWithOptions(context.Background(), productSlug, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(releases)).To(Equal([]int{4, 2, 1, 3}))
+		})
+
+		Context("when the releases span several pages", func() {
+			BeforeEach(func() {
+				releasesPath := fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug)
+
+				server.RouteToHandler("GET", releasesPath, func(w http.ResponseWriter, req *http.Request) {
+					if req.URL.Query().Get("page") == "2" {
+						ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: []pivnet.Release{
+							{ID: 5, Version: "3.0.0"},
+						}})(w, req)
+						return
+					}
+
+					ghttp.RespondWithJSONEncoded(http.StatusOK, map[string]interface{}{
+						"releases": []pivnet.Release{{ID: 1, Version: "1.10.0"}},
+						"_links":   pivnet.Links{Next: map[string]string{"href": releasesPath + "?page=2"}},
+					})(w, req)
+				})
+			})
+
+			It("applies the options to every page", func() {
+				options.SortBy = pivnet.SortByVersion
+				options.Descending = true
+				options.Limit = 1
+
+				releases, err := client.Releases.ListWithOptions(context.Background(), productSlug, options)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ids(releases)).To(Equal([]int{5}))
+			})
+		})
+
+		Context("when the sort field does not apply to releases", func() {
+			It("returns an error without listing releases", func() {
+				options.SortBy = pivnet.SortByFileType
+
+				_, err := client.Releases.ListWithOptions(context.Background(), productSlug, options)
+				Expect(err).To(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("ProductFiles.ListWithOptions", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: []pivnet.ProductFile{
+					{ID: 1, Name: "b", FileType: pivnet.FileTypeSoftware, Platforms: []string{"Linux"}},
+					{ID: 2, Name: "a", FileType: pivnet.FileTypeSoftware, Platforms: []string{"Windows", "Linux"}},
+					{ID: 3, Name: "c", FileType: pivnet.FileTypeDocumentation},
+					{ID: 4, Name: "d", FileType: pivnet.FileTypeSoftware, Platforms: []string{"Windows"}},
+				}}),
+			)
+		})
+
+		It("filters by file type and platform and sorts", func() {
+			productFiles, err := client.ProductFiles.ListWithOptions(context.Background(), productSlug, pivnet.ProductFileListOptions{
+				ListOptions: pivnet.ListOptions{SortBy: pivnet.SortByName},
+				FileTypes:   []string{"software"},
+				Platforms:   []string{"linux"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(productFiles).To(HaveLen(2))
+			Expect(productFiles[0].ID).To(Equal(2))
+			Expect(productFiles[1].ID).To(Equal(1))
+		})
+	})
+
+	Describe("FileGroups.ListWithOptions", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/file_groups", apiPrefix, productSlug),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: []pivnet.FileGroup{
+					{ID: 1, Name: "Stemcells"},
+					{ID: 2, Name: "Tiles"},
+					{ID: 3, Name: "Windows Stemcells"},
+				}}),
+			)
+		})
+
+		It("filters by name and sorts", func() {
+			fileGroups, err := client.FileGroups.ListWithOptions(productSlug, pivnet.FileGroupListOptions{
+				ListOptions:  pivnet.ListOptions{SortBy: pivnet.SortByID, Descending: true, Limit: 1},
+				NameContains: "stemcell",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fileGroups).To(HaveLen(1))
+			Expect(fileGroups[0].ID).To(Equal(3))
+		})
+	})
+
+	Describe("UserGroups.ListWithOptions", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/user_groups", apiPrefix),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.UserGroupsResponse{UserGroups: []pivnet.UserGroup{
+					{ID: 1, Name: "Partners"},
+					{ID: 2, Name: "Beta Partners"},
+					{ID: 3, Name: "Employees"},
+				}}),
+			)
+		})
+
+		It("filters by name and sorts", func() {
+			userGroups, err := client.UserGroups.ListWithOptions(pivnet.UserGroupListOptions{
+				ListOptions:  pivnet.ListOptions{SortBy: pivnet.SortByName},
+				NameContains: "partners",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(userGroups).To(HaveLen(2))
+			Expect(userGroups[0].Name).To(Equal("Beta Partners"))
+			Expect(userGroups[1].Name).To(Equal("Partners"))
+		})
+	})
+})
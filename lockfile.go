@@ -0,0 +1,421 @@
+package pivnet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	lockfileMD5Prefix    = "md5:"
+	lockfileSHA256Prefix = "sha256:"
+)
+
+// LockfileEntry pins a single downloaded product file.
+type LockfileEntry struct {
+	ProductSlug    string `json:"product_slug" yaml:"product_slug"`
+	ReleaseVersion string `json:"release_version" yaml:"release_version"`
+	ReleaseID      int    `json:"release_id" yaml:"release_id"`
+	ProductFileID  int    `json:"product_file_id" yaml:"product_file_id"`
+	AWSObjectKey   string `json:"aws_object_key" yaml:"aws_object_key"`
+	Size           int64  `json:"size" yaml:"size"`
+	MD5            string `json:"md5,omitempty" yaml:"md5,omitempty"`
+	SHA256         string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+}
+
+// Lockfile records the product files fetched by a job so that the same
+// files can be fetched again elsewhere. Its text form has one entry per
+// line, similar to go.sum:
+//
+//	<product slug> <release version> <release ID> <product file ID> <AWS object key> <size> md5:<hex> sha256:<hex>
+//
+// The slug, version and object key are URL path-escaped. Blank lines and
+// lines starting with # are ignored.
+//
+// A Lockfile is safe for concurrent use.
+type Lockfile struct {
+	mu      sync.Mutex
+	entries map[lockfileKey]LockfileEntry
+}
+
+type lockfileKey struct {
+	productSlug   string
+	releaseID     int
+	productFileID int
+}
+
+type ErrLockfileDrift struct {
+	ProductSlug   string `json:"product_slug" yaml:"product_slug"`
+	ReleaseID     int    `json:"release_id" yaml:"release_id"`
+	ProductFileID int    `json:"product_file_id" yaml:"product_file_id"`
+	Field         string `json:"field" yaml:"field"`
+	Pinned        string `json:"pinned" yaml:"pinned"`
+	Current       string `json:"current" yaml:"current"`
+}
+
+func (e ErrLockfileDrift) Error() string {
+	return fmt.Sprintf(
+		"product file %d of %s release %d has drifted from the lockfile: %s is %s, pinned %s",
+		e.ProductFileID,
+		e.ProductSlug,
+		e.ReleaseID,
+		e.Field,
+		e.Current,
+		e.Pinned,
+	)
+}
+
+func NewLockfile() *Lockfile {
+	return &Lockfile{
+		entries: map[lockfileKey]LockfileEntry{},
+	}
+}
+
+func ParseLockfile(r io.Reader) (*Lockfile, error) {
+	lockfile := NewLockfile()
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseLockfileLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("lockfile line %d: %s", lineNumber, err)
+		}
+
+		lockfile.Add(entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lockfile, nil
+}
+
+func parseLockfileLine(line string) (LockfileEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 6 {
+		return LockfileEntry{}, fmt.Errorf("expected at least 6 fields, found %d", len(fields))
+	}
+
+	var (
+		entry LockfileEntry
+		err   error
+	)
+
+	entry.ProductSlug, err = url.PathUnescape(fields[0])
+	if err != nil {
+		return LockfileEntry{}, err
+	}
+
+	entry.ReleaseVersion, err = url.PathUnescape(fields[1])
+	if err != nil {
+		return LockfileEntry{}, err
+	}
+
+	entry.ReleaseID, err = strconv.Atoi(fields[2])
+	if err != nil {
+		return LockfileEntry{}, fmt.Errorf("invalid release ID: %s", fields[2])
+	}
+
+	entry.ProductFileID, err = strconv.Atoi(fields[3])
+	if err != nil {
+		return LockfileEntry{}, fmt.Errorf("invalid product file ID: %s", fields[3])
+	}
+
+	entry.AWSObjectKey, err = url.PathUnescape(fields[4])
+	if err != nil {
+		return LockfileEntry{}, err
+	}
+
+	entry.Size, err = strconv.ParseInt(fields[5], 10, 64)
+	if err != nil {
+		return LockfileEntry{}, fmt.Errorf("invalid size: %s", fields[5])
+	}
+
+	for _, checksum := range fields[6:] {
+		switch {
+		case strings.HasPrefix(checksum, lockfileMD5Prefix):
+			entry.MD5 = strings.TrimPrefix(checksum, lockfileMD5Prefix)
+		case strings.HasPrefix(checksum, lockfileSHA256Prefix):
+			entry.SHA256 = strings.TrimPrefix(checksum, lockfileSHA256Prefix)
+		default:
+			return LockfileEntry{}, fmt.Errorf("unknown checksum: %s", checksum)
+		}
+	}
+
+	return entry, nil
+}
+
+// Add records entry, replacing any existing entry for the same product file
+// of the same release.
+func (l *Lockfile) Add(entry LockfileEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[lockfileKey{
+		productSlug:   entry.ProductSlug,
+		releaseID:     entry.ReleaseID,
+		productFileID: entry.ProductFileID,
+	}] = entry
+}
+
+// Entries returns the entries ordered by product slug, release ID and
+// product file ID.
+func (l *Lockfile) Entries() []LockfileEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]LockfileEntry, 0, len(l.entries))
+	for _, entry := range l.entries {
+		entries = append(entries, entry)
+	}
+
+	sort.Sort(lockfileEntriesByKey(entries))
+
+	return entries
+}
+
+// WriteTo writes the text form of the lockfile to w.
+func (l *Lockfile) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+
+	for _, entry := range l.Entries() {
+		fields := []string{
+			lockfileEscape(entry.ProductSlug),
+			lockfileEscape(entry.ReleaseVersion),
+			strconv.Itoa(entry.ReleaseID),
+			strconv.Itoa(entry.ProductFileID),
+			lockfileEscape(entry.AWSObjectKey),
+			strconv.FormatInt(entry.Size, 10),
+		}
+
+		if entry.MD5 != "" {
+			fields = append(fields, lockfileMD5Prefix+entry.MD5)
+		}
+
+		if entry.SHA256 != "" {
+			fields = append(fields, lockfileSHA256Prefix+entry.SHA256)
+		}
+
+		n, err := io.WriteString(w, strings.Join(fields, " ")+"\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+type DownloadLockedConfig struct {
+	Lockfile *Lockfile
+
+	// Dir is the existing directory the pinned files are written to.
+	Dir string
+
+	// Concurrency is the number of files downloaded in parallel.
+	// It defaults to 4.
+	Concurrency int
+
+	Options DownloadOptions
+}
+
+// DownloadLocked downloads exactly the product files pinned by the lockfile.
+// Before anything is downloaded, every pinned release and product file is
+// checked against Pivnet and an ErrLockfileDrift is returned for the first
+// difference. Every entry must pin a checksum, and downloaded contents must
+// match the pinned checksums and size.
+func (p ProductFilesService) DownloadLocked(config DownloadLockedConfig) ([]DownloadedFile, error) {
+	entries := config.Lockfile.Entries()
+
+	pinned := make([]ProductFile, len(entries))
+	releases := map[lockfileKey]Release{}
+
+	for i, entry := range entries {
+		if entry.MD5 == "" && entry.SHA256 == "" {
+			return nil, fmt.Errorf(
+				"product file %d of %s release %d has no pinned checksum",
+				entry.ProductFileID,
+				entry.ProductSlug,
+				entry.ReleaseID,
+			)
+		}
+
+		releaseKey := lockfileKey{productSlug: entry.ProductSlug, releaseID: entry.ReleaseID}
+
+		release, ok := releases[releaseKey]
+		if !ok {
+			var err error
+			release, err = ReleasesService{client: p.client, l: p.client.logger}.Get(entry.ProductSlug, entry.ReleaseID)
+			if err != nil {
+				return nil, err
+			}
+			releases[releaseKey] = release
+		}
+
+		pf, err := p.GetForRelease(entry.ProductSlug, entry.ReleaseID, entry.ProductFileID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkDrift(entry, release, pf)
+		if err != nil {
+			return nil, err
+		}
+
+		pf.MD5 = entry.MD5
+		pf.SHA256 = entry.SHA256
+		pinned[i] = pf
+	}
+
+	err := checkFileNames(pinned)
+	if err != nil {
+		return nil, err
+	}
+
+	return downloadConcurrently(len(entries), config.Concurrency, func(i int) (DownloadedFile, error) {
+		filePath := filepath.Join(config.Dir, pinned[i].FileName())
+		entry := entries[i]
+
+		result, err := p.downloadToPath(
+			filePath,
+			entry.ProductSlug,
+			entry.ReleaseID,
+			pinned[i],
+			config.Options,
+			func(size int64) error {
+				if size == entry.Size {
+					return nil
+				}
+
+				return ErrLockfileDrift{
+					ProductSlug:   entry.ProductSlug,
+					ReleaseID:     entry.ReleaseID,
+					ProductFileID: entry.ProductFileID,
+					Field:         "downloaded size",
+					Pinned:        strconv.FormatInt(entry.Size, 10),
+					Current:       strconv.FormatInt(size, 10),
+				}
+			},
+		)
+		if err != nil {
+			return DownloadedFile{}, err
+		}
+
+		return DownloadedFile{
+			ProductFile: pinned[i],
+			Path:        filePath,
+			Result:      result,
+		}, nil
+	})
+}
+
+func checkDrift(entry LockfileEntry, release Release, productFile ProductFile) error {
+	drift := func(field string, pinned string, current string) error {
+		return ErrLockfileDrift{
+			ProductSlug:   entry.ProductSlug,
+			ReleaseID:     entry.ReleaseID,
+			ProductFileID: entry.ProductFileID,
+			Field:         field,
+			Pinned:        pinned,
+			Current:       current,
+		}
+	}
+
+	if release.Version != entry.ReleaseVersion {
+		return drift("release version", entry.ReleaseVersion, release.Version)
+	}
+
+	if productFile.AWSObjectKey != entry.AWSObjectKey {
+		return drift("AWS object key", entry.AWSObjectKey, productFile.AWSObjectKey)
+	}
+
+	if productFile.Size != 0 && int64(productFile.Size) != entry.Size {
+		return drift("size", strconv.FormatInt(entry.Size, 10), strconv.Itoa(productFile.Size))
+	}
+
+	if productFile.MD5 != "" && entry.MD5 != "" && !strings.EqualFold(productFile.MD5, entry.MD5) {
+		return drift("MD5", entry.MD5, productFile.MD5)
+	}
+
+	if productFile.SHA256 != "" && entry.SHA256 != "" && !strings.EqualFold(productFile.SHA256, entry.SHA256) {
+		return drift("SHA256", entry.SHA256, productFile.SHA256)
+	}
+
+	return nil
+}
+
+// recordRelease adds the files downloaded from a release to the lockfile.
+func (p ProductFilesService) recordRelease(
+	lockfile *Lockfile,
+	productSlug string,
+	releaseID int,
+	downloaded []DownloadedFile,
+) error {
+	release, err := ReleasesService{client: p.client, l: p.client.logger}.Get(productSlug, releaseID)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range downloaded {
+		entry := LockfileEntry{
+			ProductSlug:    productSlug,
+			ReleaseVersion: release.Version,
+			ReleaseID:      releaseID,
+			ProductFileID:  file.ProductFile.ID,
+			AWSObjectKey:   file.ProductFile.AWSObjectKey,
+			Size:           int64(file.ProductFile.Size),
+			MD5:            file.ProductFile.MD5,
+			SHA256:         file.ProductFile.SHA256,
+		}
+
+		if entry.Size == 0 {
+			entry.Size = file.Result.Size
+		}
+
+		if entry.MD5 == "" {
+			entry.MD5 = file.Result.MD5
+		}
+
+		if entry.SHA256 == "" {
+			entry.SHA256 = file.Result.SHA256
+		}
+
+		lockfile.Add(entry)
+	}
+
+	return nil
+}
+
+// lockfileEscape escapes s so that it forms a single field, leaving slashes
+// intact to keep object keys readable.
+func lockfileEscape(s string) string {
+	return strings.Replace(url.PathEscape(s), "%2F", "/", -1)
+}
+
+type lockfileEntriesByKey []LockfileEntry
+
+func (l lockfileEntriesByKey) Len() int      { return len(l) }
+func (l lockfileEntriesByKey) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l lockfileEntriesByKey) Less(i, j int) bool {
+	if l[i].ProductSlug != l[j].ProductSlug {
+		return l[i].ProductSlug < l[j].ProductSlug
+	}
+
+	if l[i].ReleaseID != l[j].ReleaseID {
+		return l[i].ReleaseID < l[j].ReleaseID
+	}
+
+	return l[i].ProductFileID < l[j].ProductFileID
+}
@@ -0,0 +1,369 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - lockfile", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		dir string
+
+		release       pivnet.Release
+		productFileID int
+		fileContents  []byte
+		productFile   pivnet.ProductFile
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		var err error
+		dir, err = ioutil.TempDir("", "lockfile")
+		Expect(err).NotTo(HaveOccurred())
+
+		release = pivnet.Release{ID: 12, Version: "1.2.3"}
+		productFileID = 1234
+		fileContents = []byte("some file contents")
+
+		productFile = pivnet.ProductFile{
+			ID:           productFileID,
+			AWSObjectKey: "product-files/p/file.tgz",
+			Size:         len(fileContents),
+			Links: &pivnet.Links{
+				Download: map[string]string{
+					"href": fmt.Sprintf("/products/%s/releases/%d/product_files/%d/download", productSlug, release.ID, productFileID),
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, release.ID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, release),
+		)
+
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, release.ID, productFileID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: productFile}),
+		)
+
+		server.RouteToHandler("POST",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d/download", apiPrefix, productSlug, release.ID, productFileID),
+			func(w http.ResponseWriter, req *http.Request) {
+				w.Write(fileContents)
+			},
+		)
+	})
+
+	md5Of := func(contents []byte) string {
+		sum := md5.Sum(contents)
+		return hex.EncodeToString(sum[:])
+	}
+
+	sha256Of := func(contents []byte) string {
+		sum := sha256.Sum256(contents)
+		return hex.EncodeToString(sum[:])
+	}
+
+	Describe("ParseLockfile", func() {
+		It("round trips through WriteTo", func() {
+			text := strings.Join([]string{
+				"# pinned downloads",
+				"",
+				"other-product 2.0.0 7 70 product-files/o/file%20name.zip 10 md5:aa sha256:bb",
+				"some-product 1.0.0 3 30 product-files/p/file.tgz 5 sha256:cc",
+				"",
+			}, "\n")
+
+			lockfile, err := pivnet.ParseLockfile(strings.NewReader(text))
+			Expect(err).NotTo(HaveOccurred())
+
+			entries := lockfile.Entries()
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0]).To(Equal(pivnet.LockfileEntry{
+				ProductSlug:    "other-product",
+				ReleaseVersion: "2.0.0",
+				ReleaseID:      7,
+				ProductFileID:  70,
+				AWSObjectKey:   "product-files/o/file name.zip",
+				Size:           10,
+				MD5:            "aa",
+				SHA256:         "bb",
+			}))
+
+			var b bytes.Buffer
+			_, err = lockfile.WriteTo(&b)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(b.String()).To(Equal(
+				"other-product 2.0.0 7 70 product-files/o/file%20name.zip 10 md5:aa sha256:bb\n" +
+					"some-product 1.0.0 3 30 product-files/p/file.tgz 5 sha256:cc\n",
+			))
+		})
+
+		It("returns an error for malformed lines", func() {
+			_, err := pivnet.ParseLockfile(strings.NewReader("some-product 1.0.0 three 30 key 5\n"))
+			Expect(err).To(MatchError(ContainSubstring("line 1")))
+		})
+	})
+
+	It("records downloads into the lockfile", func() {
+		lockfile := pivnet.NewLockfile()
+
+		_, err := client.ProductFiles.DownloadForReleaseWithOptions(
+			bytes.NewBuffer(nil),
+			productSlug,
+			release.ID,
+			productFileID,
+			pivnet.DownloadOptions{Lockfile: lockfile},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(lockfile.Entries()).To(Equal([]pivnet.LockfileEntry{
+			{
+				ProductSlug:    productSlug,
+				ReleaseVersion: release.Version,
+				ReleaseID:      release.ID,
+				ProductFileID:  productFileID,
+				AWSObjectKey:   productFile.AWSObjectKey,
+				Size:           int64(len(fileContents)),
+				MD5:            md5Of(fileContents),
+				SHA256:         sha256Of(fileContents),
+			},
+		}))
+	})
+
+	Describe("DownloadLocked", func() {
+		var (
+			lockfile *pivnet.Lockfile
+			config   pivnet.DownloadLockedConfig
+		)
+
+		BeforeEach(func() {
+			lockfile = pivnet.NewLockfile()
+			lockfile.Add(pivnet.LockfileEntry{
+				ProductSlug:    productSlug,
+				ReleaseVersion: release.Version,
+				ReleaseID:      release.ID,
+				ProductFileID:  productFileID,
+				AWSObjectKey:   productFile.AWSObjectKey,
+				Size:           int64(len(fileContents)),
+				SHA256:         sha256Of(fileContents),
+			})
+
+			config = pivnet.DownloadLockedConfig{
+				Lockfile: lockfile,
+				Dir:      dir,
+			}
+		})
+
+		It("downloads the pinned files", func() {
+			files, err := client.ProductFiles.DownloadLocked(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(files).To(HaveLen(1))
+			Expect(files[0].Path).To(Equal(filepath.Join(dir, "file.tgz")))
+
+			contents, err := ioutil.ReadFile(files[0].Path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents).To(Equal(fileContents))
+		})
+
+		Context("when the release version has changed", func() {
+			BeforeEach(func() {
+				release.Version = "1.2.4"
+			})
+
+			It("returns an ErrLockfileDrift without downloading", func() {
+				_, err := client.ProductFiles.DownloadLocked(config)
+				Expect(err).To(MatchError(pivnet.ErrLockfileDrift{
+					ProductSlug:   productSlug,
+					ReleaseID:     release.ID,
+					ProductFileID: productFileID,
+					Field:         "release version",
+					Pinned:        "1.2.3",
+					Current:       "1.2.4",
+				}))
+
+				for _, req := range server.ReceivedRequests() {
+					Expect(req.Method).NotTo(Equal("POST"))
+				}
+			})
+		})
+
+		Context("when the product file has been replaced", func() {
+			BeforeEach(func() {
+				productFile.AWSObjectKey = "product-files/p/other.tgz"
+			})
+
+			It("returns an ErrLockfileDrift", func() {
+				_, err := client.ProductFiles.DownloadLocked(config)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrLockfileDrift{}))
+				Expect(err.(pivnet.ErrLockfileDrift).Field).To(Equal("AWS object key"))
+			})
+		})
+
+		Context("when the downloaded contents do not match the pinned checksum", func() {
+			BeforeEach(func() {
+				fileContents = []byte("tampered contents!")
+			})
+
+			It("returns an ErrChecksumMismatch and leaves nothing in the directory", func() {
+				_, err := client.ProductFiles.DownloadLocked(config)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrChecksumMismatch{}))
+
+				entries, err := ioutil.ReadDir(dir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(BeEmpty())
+			})
+		})
+
+		Context("when the downloaded size does not match the pinned size", func() {
+			BeforeEach(func() {
+				productFile.Size = 0
+
+				lockfile.Add(pivnet.LockfileEntry{
+					ProductSlug:    productSlug,
+					ReleaseVersion: release.Version,
+					ReleaseID:      release.ID,
+					ProductFileID:  productFileID,
+					AWSObjectKey:   productFile.AWSObjectKey,
+					Size:           int64(len(fileContents)) + 1,
+					SHA256:         sha256Of(fileContents),
+				})
+			})
+
+			It("returns an ErrLockfileDrift and leaves nothing in the directory", func() {
+				_, err := client.ProductFiles.DownloadLocked(config)
+				Expect(err).To(MatchError(pivnet.ErrLockfileDrift{
+					ProductSlug:   productSlug,
+					ReleaseID:     release.ID,
+					ProductFileID: productFileID,
+					Field:         "downloaded size",
+					Pinned:        fmt.Sprintf("%d", len(fileContents)+1),
+					Current:       fmt.Sprintf("%d", len(fileContents)),
+				}))
+
+				entries, err := ioutil.ReadDir(dir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(BeEmpty())
+			})
+
+			It("leaves a file already at the path alone", func() {
+				existing := filepath.Join(dir, "file.tgz")
+				Expect(ioutil.WriteFile(existing, []byte("previous download"), 0644)).To(Succeed())
+
+				_, err := client.ProductFiles.DownloadLocked(config)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrLockfileDrift{}))
+
+				contents, err := ioutil.ReadFile(existing)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("previous download"))
+			})
+		})
+
+		Context("when an entry pins no checksum", func() {
+			BeforeEach(func() {
+				lockfile.Add(pivnet.LockfileEntry{
+					ProductSlug:    productSlug,
+					ReleaseVersion: release.Version,
+					ReleaseID:      release.ID,
+					ProductFileID:  productFileID,
+					AWSObjectKey:   productFile.AWSObjectKey,
+					Size:           int64(len(fileContents)),
+				})
+			})
+
+			It("returns an error without downloading", func() {
+				_, err := client.ProductFiles.DownloadLocked(config)
+				Expect(err).To(MatchError(fmt.Sprintf(
+					"product file %d of %s release %d has no pinned checksum",
+					productFileID,
+					productSlug,
+					release.ID,
+				)))
+
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when two pinned files have the same file name", func() {
+			var otherFile pivnet.ProductFile
+
+			BeforeEach(func() {
+				otherFile = pivnet.ProductFile{
+					ID:           5678,
+					AWSObjectKey: "product-files/q/file.tgz",
+				}
+
+				lockfile.Add(pivnet.LockfileEntry{
+					ProductSlug:    productSlug,
+					ReleaseVersion: release.Version,
+					ReleaseID:      release.ID,
+					ProductFileID:  otherFile.ID,
+					AWSObjectKey:   otherFile.AWSObjectKey,
+					Size:           int64(len(fileContents)),
+					SHA256:         sha256Of(fileContents),
+				})
+			})
+
+			JustBeforeEach(func() {
+				server.RouteToHandler("GET",
+					fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, release.ID, otherFile.ID),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: otherFile}),
+				)
+			})
+
+			It("returns an error without downloading", func() {
+				_, err := client.ProductFiles.DownloadLocked(config)
+				Expect(err).To(MatchError(ContainSubstring("have the same file name: file.tgz")))
+
+				for _, req := range server.ReceivedRequests() {
+					Expect(req.Method).NotTo(Equal("POST"))
+				}
+			})
+		})
+	})
+})
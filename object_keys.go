@@ -0,0 +1,169 @@
+package pivnet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+)
+
+const (
+	objectKeyPrefix = "product_files/"
+
+	// DefaultObjectKeyTemplate places files under the product's S3
+	// directory, one directory per release version.
+	DefaultObjectKeyTemplate = "{{.S3Directory}}/{{.ReleaseVersion}}/{{.FileName}}"
+)
+
+type ObjectKeyConfig struct {
+	ProductSlug    string
+	ReleaseVersion string
+
+	// FileName is the name of the file being uploaded. Any directory
+	// components are dropped.
+	FileName string
+
+	// Template is a text/template rendered with ObjectKeyData.
+	// It defaults to DefaultObjectKeyTemplate.
+	Template string
+}
+
+// ObjectKeyData is the data an object key template is rendered with.
+type ObjectKeyData struct {
+	ProductSlug    string
+	ReleaseVersion string
+	FileName       string
+
+	// S3Directory is the product's S3 directory without a leading slash,
+	// e.g. product_files/Pivotal-CF, or product_files/<product slug> if the
+	// product has none.
+	S3Directory string
+}
+
+type ErrInvalidObjectKey struct {
+	Key    string `json:"key" yaml:"key"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+func (e ErrInvalidObjectKey) Error() string {
+	return fmt.Sprintf("invalid AWS object key %q: %s", e.Key, e.Reason)
+}
+
+type ErrObjectKeyCollision struct {
+	Key           string `json:"key" yaml:"key"`
+	ProductFileID int    `json:"product_file_id" yaml:"product_file_id"`
+}
+
+func (e ErrObjectKeyCollision) Error() string {
+	return fmt.Sprintf("AWS object key %s is already used by product file %d", e.Key, e.ProductFileID)
+}
+
+// ObjectKey derives the AWS object key for a new product file from the
+// config's template. The key must lie within the product's S3 directory and
+// must not be used by any of the product's existing product files; otherwise
+// ErrInvalidObjectKey or ErrObjectKeyCollision is returned.
+func (p ProductFilesService) ObjectKey(config ObjectKeyConfig) (string, error) {
+	products := ProductsService{client: p.client, l: p.client.logger}
+
+	product, err := products.Get(config.ProductSlug)
+	if err != nil {
+		return "", err
+	}
+
+	s3Directory := objectKeyPrefix + config.ProductSlug
+	if product.S3Directory != nil && product.S3Directory.Path != "" {
+		s3Directory = strings.Trim(product.S3Directory.Path, "/")
+	}
+
+	key, err := renderObjectKey(config, ObjectKeyData{
+		ProductSlug:    config.ProductSlug,
+		S3Directory:    s3Directory,
+		ReleaseVersion: config.ReleaseVersion,
+		FileName:       path.Base(config.FileName),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = validateObjectKey(key, s3Directory)
+	if err != nil {
+		return "", err
+	}
+
+	it := p.Iterate(context.Background(), config.ProductSlug)
+	for it.Next() {
+		if pf := it.ProductFile(); pf.AWSObjectKey == key {
+			return "", ErrObjectKeyCollision{Key: key, ProductFileID: pf.ID}
+		}
+	}
+
+	if it.Err() != nil {
+		return "", it.Err()
+	}
+
+	return key, nil
+}
+
+func renderObjectKey(config ObjectKeyConfig, data ObjectKeyData) (string, error) {
+	text := config.Template
+	if text == "" {
+		text = DefaultObjectKeyTemplate
+	}
+
+	tmpl, err := template.New("object key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	err = tmpl.Execute(&b, data)
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func validateObjectKey(key string, s3Directory string) error {
+	invalid := func(reason string) error {
+		return ErrInvalidObjectKey{Key: key, Reason: reason}
+	}
+
+	if !strings.HasPrefix(key, objectKeyPrefix) {
+		return invalid("must start with " + objectKeyPrefix)
+	}
+
+	if !strings.HasPrefix(key, s3Directory+"/") {
+		return invalid("must be within the product's S3 directory " + s3Directory)
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		switch segment {
+		case "":
+			return invalid("must not contain empty path segments")
+		case ".", "..":
+			return invalid("must not contain relative path segments")
+		}
+	}
+
+	for _, c := range key {
+		if !isObjectKeyChar(c) {
+			return invalid(fmt.Sprintf("must not contain %q", c))
+		}
+	}
+
+	return nil
+}
+
+// isObjectKeyChar reports whether c is safe to use in an object key without
+// escaping.
+func isObjectKeyChar(c rune) bool {
+	switch {
+	case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		return true
+	}
+
+	return strings.ContainsRune("-_.+/", c)
+}
@@ -0,0 +1,196 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - object keys", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		product      pivnet.Product
+		productFiles []pivnet.ProductFile
+		config       pivnet.ObjectKeyConfig
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		product = pivnet.Product{
+			ID:          3,
+			Slug:        productSlug,
+			S3Directory: &pivnet.S3Directory{Path: "/product_files/Some-Product"},
+		}
+
+		productFiles = []pivnet.ProductFile{
+			{ID: 1, AWSObjectKey: "product_files/Some-Product/1.0.0/file.tgz"},
+		}
+
+		config = pivnet.ObjectKeyConfig{
+			ProductSlug:    productSlug,
+			ReleaseVersion: "1.2.3",
+			FileName:       "/some/local/dir/file.tgz",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, product),
+		)
+
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles}),
+		)
+	})
+
+	Describe("ObjectKey", func() {
+		It("places the file in a release directory of the product's S3 directory", func() {
+			key, err := client.ProductFiles.ObjectKey(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key).To(Equal("product_files/Some-Product/1.2.3/file.tgz"))
+		})
+
+		Context("when a template is given", func() {
+			BeforeEach(func() {
+				config.Template = "{{.S3Directory}}/{{.ProductSlug}}-{{.ReleaseVersion}}-{{.FileName}}"
+			})
+
+			It("renders it", func() {
+				key, err := client.ProductFiles.ObjectKey(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(key).To(Equal(fmt.Sprintf("product_files/Some-Product/%s-1.2.3-file.tgz", productSlug)))
+			})
+		})
+
+		Context("when the template refers to unknown fields", func() {
+			BeforeEach(func() {
+				config.Template = "{{.S3Directory}}/{{.Unknown}}"
+			})
+
+			It("returns an error", func() {
+				_, err := client.ProductFiles.ObjectKey(config)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when the product has no S3 directory", func() {
+			BeforeEach(func() {
+				product.S3Directory = nil
+			})
+
+			It("uses a directory named after the product slug", func() {
+				key, err := client.ProductFiles.ObjectKey(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(key).To(Equal(fmt.Sprintf("product_files/%s/1.2.3/file.tgz", productSlug)))
+			})
+		})
+
+		Context("when the key is outside the product's S3 directory", func() {
+			BeforeEach(func() {
+				config.Template = "product_files/Other-Product/{{.FileName}}"
+			})
+
+			It("returns an ErrInvalidObjectKey", func() {
+				_, err := client.ProductFiles.ObjectKey(config)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrInvalidObjectKey{}))
+			})
+		})
+
+		Context("when the key contains relative path segments", func() {
+			BeforeEach(func() {
+				config.ReleaseVersion = ".."
+			})
+
+			It("returns an ErrInvalidObjectKey", func() {
+				_, err := client.ProductFiles.ObjectKey(config)
+				Expect(err).To(MatchError(pivnet.ErrInvalidObjectKey{
+					Key:    "product_files/Some-Product/../file.tgz",
+					Reason: "must not contain relative path segments",
+				}))
+			})
+		})
+
+		Context("when the key contains unsafe characters", func() {
+			BeforeEach(func() {
+				config.FileName = "some file.tgz"
+			})
+
+			It("returns an ErrInvalidObjectKey", func() {
+				_, err := client.ProductFiles.ObjectKey(config)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrInvalidObjectKey{}))
+			})
+		})
+
+		Context("when an existing product file uses the key", func() {
+			BeforeEach(func() {
+				config.ReleaseVersion = "1.0.0"
+			})
+
+			It("returns an ErrObjectKeyCollision", func() {
+				_, err := client.ProductFiles.ObjectKey(config)
+				Expect(err).To(MatchError(pivnet.ErrObjectKeyCollision{
+					Key:           "product_files/Some-Product/1.0.0/file.tgz",
+					ProductFileID: 1,
+				}))
+			})
+
+			Context("on a later page of product files", func() {
+				JustBeforeEach(func() {
+					productFilesPath := fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug)
+
+					server.RouteToHandler("GET", productFilesPath, func(w http.ResponseWriter, req *http.Request) {
+						if req.URL.Query().Get("page") == "2" {
+							ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles})(w, req)
+							return
+						}
+
+						ghttp.RespondWithJSONEncoded(http.StatusOK, map[string]interface{}{
+							"product_files": []pivnet.ProductFile{{ID: 2, AWSObjectKey: "product_files/Some-Product/other.tgz"}},
+							"_links":        pivnet.Links{Next: map[string]string{"href": productFilesPath + "?page=2"}},
+						})(w, req)
+					})
+				})
+
+				It("returns an ErrObjectKeyCollision", func() {
+					_, err := client.ProductFiles.ObjectKey(config)
+					Expect(err).To(MatchError(pivnet.ErrObjectKeyCollision{
+						Key:           "product_files/Some-Product/1.0.0/file.tgz",
+						ProductFileID: 1,
+					}))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,17 @@
+package pivnet
+
+// OutputFormat is a format reports can be written in. Every report can be
+// written as text or JSON; the Write method of each report lists the other
+// formats it supports and returns an error for the rest.
+type OutputFormat string
+
+const (
+	OutputFormatText OutputFormat = "text"
+	OutputFormatJSON OutputFormat = "json"
+
+	// OutputFormatMarkdown is only supported by ReleaseDiff.
+	OutputFormatMarkdown OutputFormat = "markdown"
+
+	// OutputFormatCSV is only supported by EOLReport.
+	OutputFormatCSV OutputFormat = "csv"
+)
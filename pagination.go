@@ -0,0 +1,214 @@
+package pivnet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+// pager fetches the pages of a collection one at a time by following the
+// next links Pivnet includes in each page.
+type pager struct {
+	client  Client
+	ctx     context.Context
+	next    string
+	visited map[string]bool
+	err     error
+}
+
+func newPager(ctx context.Context, client Client, endpoint string) *pager {
+	return &pager{
+		client:  client,
+		ctx:     ctx,
+		next:    endpoint,
+		visited: map[string]bool{},
+	}
+}
+
+// fetch decodes the next page into response and reports whether there was
+// one. It returns false once every page has been fetched or on error.
+func (p *pager) fetch(response interface{}, links func() *Links) bool {
+	if p.err != nil || p.next == "" {
+		return false
+	}
+
+	if p.cancelled() {
+		return false
+	}
+
+	if p.visited[p.next] {
+		p.err = fmt.Errorf("pagination loops back to %s", p.next)
+		return false
+	}
+	p.visited[p.next] = true
+
+	p.client.logger.Debug("Fetching page", logger.Data{"endpoint": p.next})
+
+	resp, err := p.client.MakeRequestWithContext(p.ctx, "GET", p.next, http.StatusOK, nil)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	defer resp.Body.Close()
+
+	p.err = json.NewDecoder(resp.Body).Decode(response)
+	if p.err != nil {
+		return false
+	}
+
+	p.next = ""
+	if l := links(); l != nil {
+		p.next = l.Next["href"]
+	}
+
+	return true
+}
+
+// cancelled records the context's error, if it is done.
+func (p *pager) cancelled() bool {
+	if p.err == nil {
+		p.err = p.ctx.Err()
+	}
+
+	return p.err != nil
+}
+
+type releasesPage struct {
+	Releases []Release `json:"releases,omitempty"`
+	Links    *Links    `json:"_links,omitempty"`
+}
+
+type productFilesPage struct {
+	ProductFiles []ProductFile `json:"product_files,omitempty"`
+	Links        *Links        `json:"_links,omitempty"`
+}
+
+// ReleaseIterator streams the releases of a product, fetching a page at a
+// time. Call Next until it returns false, then check Err.
+type ReleaseIterator struct {
+	pager   *pager
+	page    []Release
+	current Release
+}
+
+// Iterate returns an iterator over every release of a product, following
+// next links across pages. Cancelling ctx stops the iteration.
+func (r ReleasesService) Iterate(ctx context.Context, productSlug string) *ReleaseIterator {
+	return &ReleaseIterator{
+		pager: newPager(ctx, r.client, fmt.Sprintf("/products/%s/releases", productSlug)),
+	}
+}
+
+// Next advances to the next release and reports whether there is one.
+func (it *ReleaseIterator) Next() bool {
+	if it.pager.cancelled() {
+		return false
+	}
+
+	for len(it.page) == 0 {
+		var response releasesPage
+		if !it.pager.fetch(&response, func() *Links { return response.Links }) {
+			return false
+		}
+
+		it.page = response.Releases
+	}
+
+	it.current, it.page = it.page[0], it.page[1:]
+
+	return true
+}
+
+// Release returns the current release.
+func (it *ReleaseIterator) Release() Release {
+	return it.current
+}
+
+// Err returns the error that ended the iteration, if any.
+func (it *ReleaseIterator) Err() error {
+	return it.pager.err
+}
+
+// ListAll returns every release of a product across all pages.
+func (r ReleasesService) ListAll(ctx context.Context, productSlug string) ([]Release, error) {
+	var releases []Release
+
+	it := r.Iterate(ctx, productSlug)
+	for it.Next() {
+		releases = append(releases, it.Release())
+	}
+
+	return releases, it.Err()
+}
+
+// ProductFileIterator streams product files, fetching a page at a time.
+// Call Next until it returns false, then check Err.
+type ProductFileIterator struct {
+	pager   *pager
+	page    []ProductFile
+	current ProductFile
+}
+
+// Iterate returns an iterator over every product file of a product,
+// following next links across pages. Cancelling ctx stops the iteration.
+func (p ProductFilesService) Iterate(ctx context.Context, productSlug string) *ProductFileIterator {
+	return &ProductFileIterator{
+		pager: newPager(ctx, p.client, fmt.Sprintf("/products/%s/product_files", productSlug)),
+	}
+}
+
+// IterateForRelease is Iterate for the product files of a release.
+func (p ProductFilesService) IterateForRelease(ctx context.Context, productSlug string, releaseID int) *ProductFileIterator {
+	return &ProductFileIterator{
+		pager: newPager(ctx, p.client, fmt.Sprintf(
+			"/products/%s/releases/%d/product_files",
+			productSlug,
+			releaseID,
+		)),
+	}
+}
+
+// Next advances to the next product file and reports whether there is one.
+func (it *ProductFileIterator) Next() bool {
+	if it.pager.cancelled() {
+		return false
+	}
+
+	for len(it.page) == 0 {
+		var response productFilesPage
+		if !it.pager.fetch(&response, func() *Links { return response.Links }) {
+			return false
+		}
+
+		it.page = response.ProductFiles
+	}
+
+	it.current, it.page = it.page[0], it.page[1:]
+
+	return true
+}
+
+// ProductFile returns the current product file.
+func (it *ProductFileIterator) ProductFile() ProductFile {
+	return it.current
+}
+
+// Err returns the error that ended the iteration, if any.
+func (it *ProductFileIterator) Err() error {
+	return it.pager.err
+}
+
+// ListAll returns every product file of a product across all pages.
+func (p ProductFilesService) ListAll(ctx context.Context, productSlug string) ([]ProductFile, error) {
+	var productFiles []ProductFile
+
+	it := p.Iterate(ctx, productSlug)
+	for it.Next() {
+		productFiles = append(productFiles, it.ProductFile())
+	}
+
+	return productFiles, it.Err()
+}
@@ -0,0 +1,187 @@
+package pivnet_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - pagination", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		releasesPath string
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		releasesPath = fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	releasesPage := func(next string, ids ...int) http.HandlerFunc {
+		releases := []pivnet.Release{}
+		for _, id := range ids {
+			releases = append(releases, pivnet.Release{ID: id})
+		}
+
+		response := map[string]interface{}{"releases": releases}
+		if next != "" {
+			response["_links"] = pivnet.Links{Next: map[string]string{"href": next}}
+		}
+
+		return ghttp.RespondWithJSONEncoded(http.StatusOK, response)
+	}
+
+	Describe("Releases.Iterate", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", releasesPath),
+					releasesPage("https://network.pivotal.io/api/v2/products/"+productSlug+"/releases?page=2", 1, 2),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", releasesPath, "page=2"),
+					releasesPage("/products/"+productSlug+"/releases?page=3"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", releasesPath, "page=3"),
+					releasesPage("", 3),
+				),
+			)
+		})
+
+		It("follows next links across pages, skipping empty ones", func() {
+			var ids []int
+
+			it := client.Releases.Iterate(context.Background(), productSlug)
+			for it.Next() {
+				ids = append(ids, it.Release().ID)
+			}
+
+			Expect(it.Err()).NotTo(HaveOccurred())
+			Expect(ids).To(Equal([]int{1, 2, 3}))
+		})
+
+		It("fetches no more pages than are consumed", func() {
+			it := client.Releases.Iterate(context.Background(), productSlug)
+			Expect(it.Next()).To(BeTrue())
+			Expect(it.Next()).To(BeTrue())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		Context("when the context is cancelled", func() {
+			It("stops with the context's error", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+
+				it := client.Releases.Iterate(ctx, productSlug)
+				Expect(it.Next()).To(BeTrue())
+
+				cancel()
+
+				Expect(it.Next()).To(BeFalse())
+				Expect(it.Err()).To(Equal(context.Canceled))
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+
+		Describe("ListAll", func() {
+			It("collects every page", func() {
+				releases, err := client.Releases.ListAll(context.Background(), productSlug)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(releases).To(HaveLen(3))
+			})
+		})
+	})
+
+	Context("when the next link loops", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("GET", releasesPath,
+				releasesPage("/products/"+productSlug+"/releases", 1),
+			)
+		})
+
+		It("returns an error", func() {
+			releases, err := client.Releases.ListAll(context.Background(), productSlug)
+			Expect(err).To(HaveOccurred())
+			Expect(releases).To(HaveLen(1))
+		})
+	})
+
+	Context("when a page cannot be fetched", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				releasesPage("/products/"+productSlug+"/releases?page=2", 1),
+				ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+			)
+		})
+
+		It("returns the items before it and the error", func() {
+			releases, err := client.Releases.ListAll(context.Background(), productSlug)
+			Expect(err.Error()).To(ContainSubstring("foo message"))
+			Expect(releases).To(HaveLen(1))
+		})
+	})
+
+	Describe("ProductFiles.IterateForRelease", func() {
+		It("follows next links across pages", func() {
+			path := fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, 12)
+
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", path),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, map[string]interface{}{
+						"product_files": []pivnet.ProductFile{{ID: 1}},
+						"_links":        pivnet.Links{Next: map[string]string{"href": path + "?page=2"}},
+					}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", path, "page=2"),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{
+						ProductFiles: []pivnet.ProductFile{{ID: 2}},
+					}),
+				),
+			)
+
+			var ids []int
+
+			it := client.ProductFiles.IterateForRelease(context.Background(), productSlug, 12)
+			for it.Next() {
+				ids = append(ids, it.ProductFile().ID)
+			}
+
+			Expect(it.Err()).NotTo(HaveOccurred())
+			Expect(ids).To(Equal([]int{1, 2}))
+		})
+	})
+})
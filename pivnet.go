@@ -1,6 +1,7 @@
 package pivnet
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -108,6 +109,7 @@ type Client struct {
 	ReleaseDependencies *ReleaseDependenciesService
 	ReleaseTypes        *ReleaseTypesService
 	ReleaseUpgradePaths *ReleaseUpgradePathsService
+	FederationToken     *FederationTokenService
 }
 
 type ClientConfig struct {
@@ -138,6 +140,7 @@ func NewClient(config ClientConfig, logger logger.Logger) Client {
 	client.ReleaseDependencies = &ReleaseDependenciesService{client: client}
 	client.ReleaseTypes = &ReleaseTypesService{client: client}
 	client.ReleaseUpgradePaths = &ReleaseUpgradePathsService{client: client}
+	client.FederationToken = &FederationTokenService{client: client}
 
 	return client
 }
@@ -147,23 +150,17 @@ func (c Client) CreateRequest(
 	endpoint string,
 	body io.Reader,
 ) (*http.Request, error) {
-	u, err := url.Parse(c.baseURL)
+	u, err := c.endpointURL(endpoint)
 	if err != nil {
 		return nil, err
 	}
 
-	endpoint = c.stripHostPrefix(endpoint)
-
-	u.Path = u.Path + endpoint
-
 	req, err := http.NewRequest(requestType, u.String(), body)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Token %s", c.token))
-	req.Header.Add("User-Agent", c.userAgent)
+	c.addPivnetHeaders(req)
 
 	return req, nil
 }
@@ -173,11 +170,24 @@ func (c Client) MakeRequest(
 	endpoint string,
 	expectedStatusCode int,
 	body io.Reader,
+) (*http.Response, error) {
+	return c.MakeRequestWithContext(context.Background(), requestType, endpoint, expectedStatusCode, body)
+}
+
+// MakeRequestWithContext is MakeRequest with a context that cancels the
+// request.
+func (c Client) MakeRequestWithContext(
+	ctx context.Context,
+	requestType string,
+	endpoint string,
+	expectedStatusCode int,
+	body io.Reader,
 ) (*http.Response, error) {
 	req, err := c.CreateRequest(requestType, endpoint, body)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
 	reqBytes, err := httputil.DumpRequestOut(req, true)
 	if err != nil {
@@ -185,16 +195,8 @@ func (c Client) MakeRequest(
 	}
 
 	c.logger.Debug("Making request", logger.Data{"request": string(reqBytes)})
-	var httpClient *http.Client
 
-	httpClient = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: c.skipSSLValidation},
-			Proxy: http.ProxyFromEnvironment,
-		},
-	}
-
-	resp, err := httpClient.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -203,54 +205,88 @@ func (c Client) MakeRequest(
 	c.logger.Debug("Response headers", logger.Data{"headers": resp.Header})
 
 	if expectedStatusCode > 0 && resp.StatusCode != expectedStatusCode {
-		var pErr pivnetErr
+		defer resp.Body.Close()
+		return nil, c.responseError(resp)
+	}
 
-		b, err := ioutil.ReadAll(resp.Body)
+	return resp, nil
+}
+
+func (c Client) responseError(resp *http.Response) error {
+	var pErr pivnetErr
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// We have to handle 500 differently because it has a different structure
+	if resp.StatusCode == http.StatusInternalServerError {
+		var internalServerError pivnetInternalServerErr
+		err = json.Unmarshal(b, &internalServerError)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		// We have to handle 500 differently because it has a different structure
-		if resp.StatusCode == http.StatusInternalServerError {
-			var internalServerError pivnetInternalServerErr
-			err = json.Unmarshal(b, &internalServerError)
-			if err != nil {
-				return nil, err
-			}
-
-			pErr = pivnetErr{
-				Message: internalServerError.Error,
-			}
-		} else {
-			err = json.Unmarshal(b, &pErr)
-			if err != nil {
-				return nil, err
-			}
+		pErr = pivnetErr{
+			Message: internalServerError.Error,
+		}
+	} else {
+		err = json.Unmarshal(b, &pErr)
+		if err != nil {
+			return err
 		}
+	}
 
-		switch resp.StatusCode {
-		case http.StatusUnauthorized:
-			return nil, newErrUnauthorized(pErr.Message)
-		case http.StatusNotFound:
-			return nil, newErrNotFound(pErr.Message)
-		case http.StatusUnavailableForLegalReasons:
-			return nil, newErrUnavailableForLegalReasons()
-		default:
-			return nil, ErrPivnetOther{
-				ResponseCode: resp.StatusCode,
-				Message:      pErr.Message,
-				Errors:       pErr.Errors,
-			}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return newErrUnauthorized(pErr.Message)
+	case http.StatusNotFound:
+		return newErrNotFound(pErr.Message)
+	case http.StatusUnavailableForLegalReasons:
+		return newErrUnavailableForLegalReasons()
+	default:
+		return ErrPivnetOther{
+			ResponseCode: resp.StatusCode,
+			Message:      pErr.Message,
+			Errors:       pErr.Errors,
 		}
 	}
+}
 
-	return resp, nil
+func (c Client) httpClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: c.skipSSLValidation},
+			Proxy:           http.ProxyFromEnvironment,
+		},
+	}
+}
+
+func (c Client) addPivnetHeaders(req *http.Request) {
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", fmt.Sprintf("Token %s", c.token))
+	req.Header.Add("User-Agent", c.userAgent)
 }
 
-func (c Client) stripHostPrefix(downloadLink string) string {
-	if strings.HasPrefix(downloadLink, apiVersion) {
-		return downloadLink
+// endpointURL resolves an endpoint against the configured host.
+// The endpoint may be a path relative to the API root, optionally with a
+// query string, or an absolute link returned by Pivnet such as
+// https://network.pivotal.io/api/v2/products/p/releases/1/product_files/2/download
+// in which case only its path and query are retained.
+func (c Client) endpointURL(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
 	}
-	sp := strings.Split(downloadLink, apiVersion)
-	return sp[len(sp)-1]
+
+	u.Path = u.Path + strings.TrimPrefix(ref.Path, apiVersion)
+	u.RawQuery = ref.RawQuery
+
+	return u, nil
 }
@@ -0,0 +1,119 @@
+package pivnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ProductFilePatch is a partial update of a product file covering every
+// field that can be written. Only the fields that are set are sent; a string
+// field set to "" is sent as null and a list set to an empty list is sent
+// as [], clearing them.
+type ProductFilePatch struct {
+	Description        *string
+	DocsURL            *string
+	FileType           *string
+	FileVersion        *string
+	IncludedFiles      *[]string
+	MD5                *string
+	Name               *string
+	Platforms          *[]string
+	ReleasedAt         *string
+	SystemRequirements *[]string
+}
+
+// Strings returns a pointer to a list of v, for setting list fields of a
+// patch. With no arguments it clears the field.
+func Strings(v ...string) *[]string {
+	if v == nil {
+		v = []string{}
+	}
+
+	return &v
+}
+
+// Patch updates only the fields the patch sets, unlike Update, which sends
+// a fixed subset of fields.
+func (p ProductFilesService) Patch(productSlug string, productFileID int, patch ProductFilePatch) (ProductFile, error) {
+	fields := patch.fields()
+	if len(fields) == 0 {
+		return ProductFile{}, fmt.Errorf("product file patch sets no fields")
+	}
+
+	return p.patchFields(productSlug, productFileID, fields)
+}
+
+func (p ProductFilesService) patchFields(productSlug string, productFileID int, fields map[string]interface{}) (ProductFile, error) {
+	url := fmt.Sprintf("/products/%s/product_files/%d", productSlug, productFileID)
+
+	b, err := json.Marshal(map[string]interface{}{"product_file": fields})
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling
+		// a known-good body
+		return ProductFile{}, err
+	}
+
+	var response ProductFileResponse
+	resp, err := p.client.MakeRequest(
+		"PATCH",
+		url,
+		http.StatusOK,
+		bytes.NewReader(b),
+	)
+	if err != nil {
+		return ProductFile{}, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	return response.ProductFile, nil
+}
+
+// fields returns the JSON fields of the product file the patch sets.
+func (p ProductFilePatch) fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	set := func(name string, value *string) {
+		if value == nil {
+			return
+		}
+
+		if *value == "" {
+			fields[name] = nil
+		} else {
+			fields[name] = *value
+		}
+	}
+
+	setList := func(name string, value *[]string) {
+		if value == nil {
+			return
+		}
+
+		if *value == nil {
+			fields[name] = []string{}
+		} else {
+			fields[name] = *value
+		}
+	}
+
+	set("description", p.Description)
+	set("docs_url", p.DocsURL)
+	set("file_type", p.FileType)
+	set("file_version", p.FileVersion)
+	set("md5", p.MD5)
+	set("name", p.Name)
+	set("released_at", p.ReleasedAt)
+
+	setList("included_files", p.IncludedFiles)
+	setList("platforms", p.Platforms)
+	setList("system_requirements", p.SystemRequirements)
+
+	return fields
+}
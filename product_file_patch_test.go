@@ -0,0 +1,113 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - product file patch", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		patchURL string
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		patchURL = fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, 1234)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Patch", func() {
+		It("sends only the fields that are set, including list fields", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PATCH", patchURL),
+					ghttp.VerifyJSON(`{"product_file":{
+						"docs_url": "https://example.com/docs",
+						"released_at": "2017-01-01",
+						"platforms": ["Linux", "Windows"],
+						"included_files": ["a.tgz"]
+					}}`),
+					ghttp.RespondWith(http.StatusOK, `{"product_file": {"id": 1234, "platforms": ["Linux", "Windows"]}}`),
+				),
+			)
+
+			productFile, err := client.ProductFiles.Patch(productSlug, 1234, pivnet.ProductFilePatch{
+				DocsURL:       pivnet.String("https://example.com/docs"),
+				ReleasedAt:    pivnet.String("2017-01-01"),
+				Platforms:     pivnet.Strings("Linux", "Windows"),
+				IncludedFiles: pivnet.Strings("a.tgz"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(productFile.Platforms).To(Equal([]string{"Linux", "Windows"}))
+		})
+
+		It("clears fields set to empty values", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PATCH", patchURL),
+					ghttp.VerifyJSON(`{"product_file":{"description": null, "system_requirements": []}}`),
+					ghttp.RespondWith(http.StatusOK, `{"product_file": {"id": 1234}}`),
+				),
+			)
+
+			_, err := client.ProductFiles.Patch(productSlug, 1234, pivnet.ProductFilePatch{
+				Description:        pivnet.String(""),
+				SystemRequirements: pivnet.Strings(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the patch sets no fields", func() {
+			It("returns an error without calling Pivnet", func() {
+				_, err := client.ProductFiles.Patch(productSlug, 1234, pivnet.ProductFilePatch{})
+				Expect(err).To(MatchError("product file patch sets no fields"))
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when the server responds with a non-200 status code", func() {
+			It("returns the error", func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PATCH", patchURL),
+						ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+					),
+				)
+
+				_, err := client.ProductFiles.Patch(productSlug, 1234, pivnet.ProductFilePatch{Name: pivnet.String("new")})
+				Expect(err.Error()).To(ContainSubstring("foo message"))
+			})
+		})
+	})
+})
@@ -0,0 +1,137 @@
+package pivnet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const (
+	FileTransferStatusInProgress = "in_progress"
+	FileTransferStatusComplete   = "complete"
+	FileTransferStatusFailed     = "failed"
+)
+
+const (
+	defaultWaitTimeout         = 30 * time.Minute
+	defaultWaitInitialInterval = 2 * time.Second
+	defaultWaitMaxInterval     = time.Minute
+)
+
+type WaitForReadyConfig struct {
+	ProductSlug   string
+	ProductFileID int
+
+	// Timeout bounds the total time spent waiting. It defaults to 30 minutes.
+	Timeout time.Duration
+
+	// InitialInterval is the delay before the second poll. Each further
+	// delay doubles, up to MaxInterval. They default to 2 seconds and
+	// 1 minute.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+
+	// OnStatus, if set, is called with the product file after every poll,
+	// including the last.
+	OnStatus func(ProductFile)
+}
+
+type ErrFileTransferFailed struct {
+	ProductFileID int    `json:"product_file_id" yaml:"product_file_id"`
+	Status        string `json:"status" yaml:"status"`
+}
+
+func (e ErrFileTransferFailed) Error() string {
+	return fmt.Sprintf("transfer of product file %d failed with status: %s", e.ProductFileID, e.Status)
+}
+
+type ErrWaitTimeout struct {
+	ProductFileID int           `json:"product_file_id" yaml:"product_file_id"`
+	Status        string        `json:"status" yaml:"status"`
+	Timeout       time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+func (e ErrWaitTimeout) Error() string {
+	return fmt.Sprintf(
+		"product file %d was not ready after %s, last status: %s",
+		e.ProductFileID,
+		e.Timeout,
+		e.Status,
+	)
+}
+
+// IsReady reports whether Pivnet has finished processing the product file.
+func (p ProductFile) IsReady() bool {
+	return p.ReadyToServe || p.FileTransferStatus == FileTransferStatusComplete
+}
+
+// WaitForReady polls a product file with exponential backoff until Pivnet
+// has finished processing it. A failed transfer is returned immediately as
+// ErrFileTransferFailed and running out of time as ErrWaitTimeout.
+func (p ProductFilesService) WaitForReady(config WaitForReadyConfig) (ProductFile, error) {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	}
+
+	interval := config.InitialInterval
+	if interval <= 0 {
+		interval = defaultWaitInitialInterval
+	}
+
+	maxInterval := config.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = defaultWaitMaxInterval
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		productFile, err := p.Get(config.ProductSlug, config.ProductFileID)
+		if err != nil {
+			return ProductFile{}, err
+		}
+
+		p.client.logger.Debug("Product file transfer status", logger.Data{
+			"productFileID":      productFile.ID,
+			"fileTransferStatus": productFile.FileTransferStatus,
+			"readyToServe":       productFile.ReadyToServe,
+		})
+
+		if config.OnStatus != nil {
+			config.OnStatus(productFile)
+		}
+
+		if productFile.IsReady() {
+			return productFile, nil
+		}
+
+		if productFile.FileTransferStatus == FileTransferStatusFailed {
+			return ProductFile{}, ErrFileTransferFailed{
+				ProductFileID: config.ProductFileID,
+				Status:        productFile.FileTransferStatus,
+			}
+		}
+
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return ProductFile{}, ErrWaitTimeout{
+				ProductFileID: config.ProductFileID,
+				Status:        productFile.FileTransferStatus,
+				Timeout:       timeout,
+			}
+		}
+
+		if interval < remaining {
+			time.Sleep(interval)
+		} else {
+			time.Sleep(remaining)
+		}
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
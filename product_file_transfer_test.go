@@ -0,0 +1,144 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - product file transfer", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		productFileID int
+		config        pivnet.WaitForReadyConfig
+		statuses      []string
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		productFileID = 1234
+		statuses = nil
+
+		config = pivnet.WaitForReadyConfig{
+			ProductSlug:     productSlug,
+			ProductFileID:   productFileID,
+			Timeout:         time.Second,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     5 * time.Millisecond,
+			OnStatus: func(productFile pivnet.ProductFile) {
+				statuses = append(statuses, productFile.FileTransferStatus)
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	respondWithStatus := func(status string) http.HandlerFunc {
+		return ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, productFileID)),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+				ProductFile: pivnet.ProductFile{
+					ID:                 productFileID,
+					FileTransferStatus: status,
+				},
+			}),
+		)
+	}
+
+	Describe("WaitForReady", func() {
+		It("polls until the transfer is complete, reporting each status", func() {
+			server.AppendHandlers(
+				respondWithStatus(pivnet.FileTransferStatusInProgress),
+				respondWithStatus(pivnet.FileTransferStatusInProgress),
+				respondWithStatus(pivnet.FileTransferStatusComplete),
+			)
+
+			productFile, err := client.ProductFiles.WaitForReady(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(productFile.FileTransferStatus).To(Equal(pivnet.FileTransferStatusComplete))
+
+			Expect(statuses).To(Equal([]string{
+				pivnet.FileTransferStatusInProgress,
+				pivnet.FileTransferStatusInProgress,
+				pivnet.FileTransferStatusComplete,
+			}))
+		})
+
+		Context("when the transfer fails", func() {
+			It("returns an ErrFileTransferFailed without polling again", func() {
+				server.AppendHandlers(
+					respondWithStatus(pivnet.FileTransferStatusInProgress),
+					respondWithStatus(pivnet.FileTransferStatusFailed),
+				)
+
+				_, err := client.ProductFiles.WaitForReady(config)
+				Expect(err).To(MatchError(pivnet.ErrFileTransferFailed{
+					ProductFileID: productFileID,
+					Status:        pivnet.FileTransferStatusFailed,
+				}))
+
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when the file is not ready before the timeout", func() {
+			BeforeEach(func() {
+				config.Timeout = 20 * time.Millisecond
+			})
+
+			It("returns an ErrWaitTimeout", func() {
+				server.RouteToHandler("GET",
+					fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, productFileID),
+					respondWithStatus(pivnet.FileTransferStatusInProgress),
+				)
+
+				_, err := client.ProductFiles.WaitForReady(config)
+				Expect(err).To(MatchError(pivnet.ErrWaitTimeout{
+					ProductFileID: productFileID,
+					Status:        pivnet.FileTransferStatusInProgress,
+					Timeout:       config.Timeout,
+				}))
+			})
+		})
+
+		Context("when the server responds with a non-2XX status code", func() {
+			It("returns an error", func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+				)
+
+				_, err := client.ProductFiles.WaitForReady(config)
+				Expect(err.Error()).To(ContainSubstring("foo message"))
+			})
+		})
+	})
+})
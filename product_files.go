@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-
-	"github.com/pivotal-cf/go-pivnet/logger"
 )
 
 type ProductFilesService struct {
@@ -52,6 +50,7 @@ type ProductFile struct {
 	Platforms          []string `json:"platforms,omitempty" yaml:"platforms,omitempty"`
 	ReadyToServe       bool     `json:"ready_to_serve,omitempty" yaml:"ready_to_serve,omitempty"`
 	ReleasedAt         string   `json:"released_at,omitempty" yaml:"released_at,omitempty"`
+	SHA256             string   `json:"sha256,omitempty" yaml:"sha256,omitempty"`
 	Size               int      `json:"size,omitempty" yaml:"size,omitempty"`
 	SystemRequirements []string `json:"system_requirements,omitempty" yaml:"system_requirements,omitempty"`
 	Links              *Links   `json:"_links,omitempty" yaml:"_links,omitempty"`
@@ -177,25 +176,34 @@ func (p ProductFilesService) GetForRelease(productSlug string, releaseID int, pr
 }
 
 func (p ProductFilesService) Create(config CreateProductFileConfig) (ProductFile, error) {
+	return p.create(config, "")
+}
+
+// create creates a product file, associating the signature uploaded to
+// signatureKey with it if signatureKey is set.
+func (p ProductFilesService) create(config CreateProductFileConfig, signatureKey string) (ProductFile, error) {
 	if config.AWSObjectKey == "" {
 		return ProductFile{}, fmt.Errorf("AWS object key must not be empty")
 	}
 
 	url := fmt.Sprintf("/products/%s/product_files", config.ProductSlug)
 
-	body := createUpdateProductFileBody{
-		ProductFile: ProductFile{
-			AWSObjectKey:       config.AWSObjectKey,
-			Description:        config.Description,
-			DocsURL:            config.DocsURL,
-			FileType:           config.FileType,
-			FileVersion:        config.FileVersion,
-			IncludedFiles:      config.IncludedFiles,
-			MD5:                config.MD5,
-			Name:               config.Name,
-			Platforms:          config.Platforms,
-			ReleasedAt:         config.ReleasedAt,
-			SystemRequirements: config.SystemRequirements,
+	body := createSignedProductFileBody{
+		ProductFile: signedProductFile{
+			ProductFile: ProductFile{
+				AWSObjectKey:       config.AWSObjectKey,
+				Description:        config.Description,
+				DocsURL:            config.DocsURL,
+				FileType:           config.FileType,
+				FileVersion:        config.FileVersion,
+				IncludedFiles:      config.IncludedFiles,
+				MD5:                config.MD5,
+				Name:               config.Name,
+				Platforms:          config.Platforms,
+				ReleasedAt:         config.ReleasedAt,
+				SystemRequirements: config.SystemRequirements,
+			},
+			SignatureAWSObjectKey: signatureKey,
 		},
 	}
 
@@ -455,40 +463,45 @@ func (p ProductFilesService) DownloadForRelease(
 	releaseID int,
 	productFileID int,
 ) error {
-	pf, err := p.GetForRelease(
+	_, err := p.DownloadForReleaseWithOptions(
+		writer,
 		productSlug,
 		releaseID,
 		productFileID,
+		DownloadOptions{},
 	)
-	if err != nil {
-		return err
-	}
+	return err
+}
 
-	downloadLink, err := pf.DownloadLink()
+func (p ProductFilesService) DownloadForReleaseWithOptions(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFileID int,
+	options DownloadOptions,
+) (DownloadResult, error) {
+	pf, err := p.GetForRelease(
+		productSlug,
+		releaseID,
+		productFileID,
+	)
 	if err != nil {
-		return err
+		return DownloadResult{}, err
 	}
 
-	p.client.logger.Debug("Downloading file", logger.Data{"downloadLink": downloadLink})
-
-	resp, err := p.client.MakeRequest(
-		"POST",
-		downloadLink,
-		http.StatusOK,
-		nil,
-	)
+	result, err := p.download(writer, productSlug, releaseID, pf, options)
 	if err != nil {
-		// Untested as we cannot force CreateRequest to return an error.
-		return err
+		return DownloadResult{}, err
 	}
-	defer resp.Body.Close()
 
-	p.client.logger.Debug("Copying body", logger.Data{"downloadLink": downloadLink})
-
-	_, err = io.Copy(writer, resp.Body)
-	if err != nil {
-		return err
+	if options.Lockfile != nil {
+		err = p.recordRelease(options.Lockfile, productSlug, releaseID, []DownloadedFile{
+			{ProductFile: pf, Result: result},
+		})
+		if err != nil {
+			return DownloadResult{}, err
+		}
 	}
 
-	return nil
+	return result, nil
 }
@@ -1043,6 +1043,181 @@ var _ = Describe("PivnetClient - product files", func() {
 			})
 		})
 	})
+
+	Describe("DownloadForReleaseWithOptions", func() {
+		var (
+			releaseID     int
+			productFileID int
+
+			downloadLink string
+			fileContents []byte
+
+			options pivnet.DownloadOptions
+
+			eulaAcceptanceStatusCode int
+			retryStatusCode          int
+		)
+
+		BeforeEach(func() {
+			releaseID = 1234
+			productFileID = 2345
+
+			downloadLink = "/some/download/link"
+			fileContents = []byte("some file contents")
+
+			options = pivnet.DownloadOptions{}
+
+			eulaAcceptanceStatusCode = http.StatusOK
+			retryStatusCode = http.StatusOK
+
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest(
+						"GET",
+						fmt.Sprintf(
+							"%s/products/%s/releases/%d/product_files/%d",
+							apiPrefix,
+							productSlug,
+							releaseID,
+							productFileID,
+						),
+					),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+						pivnet.ProductFile{
+							ID: productFileID,
+							Links: &pivnet.Links{
+								Download: map[string]string{"href": downloadLink},
+							},
+						},
+					}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", fmt.Sprintf("%s%s", apiPrefix, downloadLink)),
+					ghttp.RespondWith(http.StatusUnavailableForLegalReasons, `{"message":"ignore me"}`),
+				),
+			)
+		})
+
+		Context("when the EULA has not been accepted and AcceptEULA is not set", func() {
+			It("returns an ErrUnavailableForLegalReasons without retrying", func() {
+				_, err := client.ProductFiles.DownloadForReleaseWithOptions(
+					bytes.NewBuffer(nil),
+					productSlug,
+					releaseID,
+					productFileID,
+					options,
+				)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrUnavailableForLegalReasons{}))
+
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when the EULA has not been accepted and AcceptEULA is set", func() {
+			BeforeEach(func() {
+				options.AcceptEULA = true
+			})
+
+			JustBeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest(
+							"GET",
+							fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, releaseID),
+						),
+						ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.Release{
+							ID:      releaseID,
+							Version: "1.2.3",
+							EULA:    &pivnet.EULA{Slug: "some-eula", Name: "Some EULA"},
+						}),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest(
+							"POST",
+							fmt.Sprintf("%s/products/%s/releases/%d/eula_acceptance", apiPrefix, productSlug, releaseID),
+						),
+						ghttp.RespondWith(eulaAcceptanceStatusCode, `{"message":"eula acceptance failed"}`),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", fmt.Sprintf("%s%s", apiPrefix, downloadLink)),
+						ghttp.RespondWith(retryStatusCode, fileContents),
+					),
+				)
+			})
+
+			It("accepts the EULA and retries the download", func() {
+				writer := bytes.NewBuffer(nil)
+
+				result, err := client.ProductFiles.DownloadForReleaseWithOptions(
+					writer,
+					productSlug,
+					releaseID,
+					productFileID,
+					options,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(writer.Bytes()).To(Equal(fileContents))
+				Expect(result.Size).To(Equal(int64(len(fileContents))))
+			})
+
+			It("logs which EULA is being accepted", func() {
+				_, err := client.ProductFiles.DownloadForReleaseWithOptions(
+					bytes.NewBuffer(nil),
+					productSlug,
+					releaseID,
+					productFileID,
+					options,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				fake := fakeLogger.(*loggerfakes.FakeLogger)
+				Expect(fake.InfoCallCount()).To(Equal(1))
+
+				action, data := fake.InfoArgsForCall(0)
+				Expect(action).To(Equal("Accepting EULA"))
+				Expect(data[0]["eulaSlug"]).To(Equal("some-eula"))
+			})
+
+			Context("when accepting the EULA fails", func() {
+				BeforeEach(func() {
+					eulaAcceptanceStatusCode = http.StatusTeapot
+				})
+
+				It("returns the error", func() {
+					_, err := client.ProductFiles.DownloadForReleaseWithOptions(
+						bytes.NewBuffer(nil),
+						productSlug,
+						releaseID,
+						productFileID,
+						options,
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("eula acceptance failed"))
+				})
+			})
+
+			Context("when the retry is also unavailable for legal reasons", func() {
+				BeforeEach(func() {
+					retryStatusCode = http.StatusUnavailableForLegalReasons
+					fileContents = []byte(`{"message":"ignore me"}`)
+				})
+
+				It("returns the error without retrying again", func() {
+					_, err := client.ProductFiles.DownloadForReleaseWithOptions(
+						bytes.NewBuffer(nil),
+						productSlug,
+						releaseID,
+						productFileID,
+						options,
+					)
+					Expect(err).To(BeAssignableToTypeOf(pivnet.ErrUnavailableForLegalReasons{}))
+
+					Expect(server.ReceivedRequests()).To(HaveLen(5))
+				})
+			})
+		})
+	})
 })
 
 type errWriter struct {
@@ -0,0 +1,114 @@
+package pivnet
+
+import (
+	"fmt"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+// ReleaseAvailability is who can see and download a release.
+type ReleaseAvailability string
+
+const (
+	AvailabilityAdminsOnly             ReleaseAvailability = "Admins Only"
+	AvailabilitySelectedUserGroupsOnly ReleaseAvailability = "Selected User Groups Only"
+	AvailabilityAllUsers               ReleaseAvailability = "All Users"
+)
+
+// Valid reports whether a is one of the availabilities Pivnet accepts.
+func (a ReleaseAvailability) Valid() bool {
+	switch a {
+	case AvailabilityAdminsOnly, AvailabilitySelectedUserGroupsOnly, AvailabilityAllUsers:
+		return true
+	}
+
+	return false
+}
+
+type ErrInvalidAvailability struct {
+	ProductSlug string              `json:"product_slug" yaml:"product_slug"`
+	Version     string              `json:"version" yaml:"version"`
+	From        ReleaseAvailability `json:"from" yaml:"from"`
+	To          ReleaseAvailability `json:"to" yaml:"to"`
+	Reason      string              `json:"reason" yaml:"reason"`
+}
+
+func (e ErrInvalidAvailability) Error() string {
+	return fmt.Sprintf(
+		"cannot make release %s of %s %s: %s",
+		e.Version,
+		e.ProductSlug,
+		e.To,
+		e.Reason,
+	)
+}
+
+type SetAvailabilityConfig struct {
+	ProductSlug  string
+	ReleaseID    int
+	Availability ReleaseAvailability
+
+	// UserGroupIDs are attached to the release before its availability
+	// changes. Selected User Groups Only requires the release to end up with
+	// at least one user group.
+	UserGroupIDs []int
+}
+
+// SetAvailability moves a release to the config's availability and returns
+// the updated release. The release is not updated if it would be left
+// Selected User Groups Only with no user groups, which would hide it from
+// everyone but admins.
+func (r ReleasesService) SetAvailability(config SetAvailabilityConfig) (Release, error) {
+	slug := config.ProductSlug
+
+	release, err := r.Get(slug, config.ReleaseID)
+	if err != nil {
+		return Release{}, err
+	}
+
+	invalid := func(reason string) error {
+		return ErrInvalidAvailability{
+			ProductSlug: slug,
+			Version:     release.Version,
+			From:        release.Availability,
+			To:          config.Availability,
+			Reason:      reason,
+		}
+	}
+
+	if !config.Availability.Valid() {
+		return Release{}, invalid("unknown availability")
+	}
+
+	userGroups := UserGroupsService{client: r.client}
+
+	for _, id := range config.UserGroupIDs {
+		err := userGroups.AddToRelease(slug, release.ID, id)
+		if err != nil {
+			return Release{}, err
+		}
+	}
+
+	if config.Availability == AvailabilitySelectedUserGroupsOnly {
+		attached, err := userGroups.ListForRelease(slug, release.ID)
+		if err != nil {
+			return Release{}, err
+		}
+
+		if len(attached) == 0 {
+			return Release{}, invalid("no user groups are attached")
+		}
+	}
+
+	if release.Availability == config.Availability {
+		return release, nil
+	}
+
+	r.l.Info("Changing release availability", logger.Data{
+		"version": release.Version,
+		"from":    release.Availability,
+		"to":      config.Availability,
+	})
+
+	return r.Patch(slug, release.ID, ReleasePatch{Availability: &config.Availability})
+}
@@ -0,0 +1,166 @@
+package pivnet_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release availability", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		fake    *fakePivnet
+		release pivnet.Release
+		config  pivnet.SetAvailabilityConfig
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		fake = newFakePivnet()
+		fake.routeFrom(server)
+		fake.userGroups = []pivnet.UserGroup{{ID: 8, Name: "Partners"}}
+
+		release = fake.addRelease(productSlug, pivnet.Release{
+			Version:      "1.0.0",
+			Availability: pivnet.AvailabilityAdminsOnly,
+		})
+
+		config = pivnet.SetAvailabilityConfig{
+			ProductSlug:  productSlug,
+			ReleaseID:    release.ID,
+			Availability: pivnet.AvailabilityAllUsers,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Valid", func() {
+		It("accepts only the availabilities Pivnet knows", func() {
+			Expect(pivnet.AvailabilityAdminsOnly.Valid()).To(BeTrue())
+			Expect(pivnet.AvailabilitySelectedUserGroupsOnly.Valid()).To(BeTrue())
+			Expect(pivnet.AvailabilityAllUsers.Valid()).To(BeTrue())
+			Expect(pivnet.ReleaseAvailability("all users").Valid()).To(BeFalse())
+		})
+	})
+
+	Describe("SetAvailability", func() {
+		It("updates the release", func() {
+			updated, err := client.Releases.SetAvailability(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated.Availability).To(Equal(pivnet.AvailabilityAllUsers))
+			Expect(fake.release(productSlug, release.ID).Availability).To(Equal(pivnet.AvailabilityAllUsers))
+		})
+
+		Context("when the release already has the availability", func() {
+			BeforeEach(func() {
+				config.Availability = pivnet.AvailabilityAdminsOnly
+			})
+
+			It("does not update it", func() {
+				updated, err := client.Releases.SetAvailability(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(updated.Availability).To(Equal(pivnet.AvailabilityAdminsOnly))
+				Expect(fake.writeCount()).To(BeZero())
+			})
+		})
+
+		Context("when the availability is unknown", func() {
+			BeforeEach(func() {
+				config.Availability = "Everyone"
+			})
+
+			It("returns an ErrInvalidAvailability", func() {
+				_, err := client.Releases.SetAvailability(config)
+				Expect(err).To(MatchError(pivnet.ErrInvalidAvailability{
+					ProductSlug: productSlug,
+					Version:     "1.0.0",
+					From:        pivnet.AvailabilityAdminsOnly,
+					To:          "Everyone",
+					Reason:      "unknown availability",
+				}))
+				Expect(fake.writeCount()).To(BeZero())
+			})
+		})
+
+		Context("when moving to selected user groups", func() {
+			BeforeEach(func() {
+				config.Availability = pivnet.AvailabilitySelectedUserGroupsOnly
+			})
+
+			Context("when no user groups are attached", func() {
+				It("returns an ErrInvalidAvailability without updating the release", func() {
+					_, err := client.Releases.SetAvailability(config)
+					Expect(err).To(MatchError(pivnet.ErrInvalidAvailability{
+						ProductSlug: productSlug,
+						Version:     "1.0.0",
+						From:        pivnet.AvailabilityAdminsOnly,
+						To:          pivnet.AvailabilitySelectedUserGroupsOnly,
+						Reason:      "no user groups are attached",
+					}))
+					Expect(fake.release(productSlug, release.ID).Availability).To(Equal(pivnet.AvailabilityAdminsOnly))
+				})
+			})
+
+			Context("when a user group is already attached", func() {
+				BeforeEach(func() {
+					fake.attach(release.ID, "user_group", 8)
+				})
+
+				It("updates the release", func() {
+					updated, err := client.Releases.SetAvailability(config)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(updated.Availability).To(Equal(pivnet.AvailabilitySelectedUserGroupsOnly))
+				})
+			})
+
+			Context("when user groups are given", func() {
+				BeforeEach(func() {
+					config.UserGroupIDs = []int{8}
+				})
+
+				It("attaches them before updating the release", func() {
+					updated, err := client.Releases.SetAvailability(config)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(updated.Availability).To(Equal(pivnet.AvailabilitySelectedUserGroupsOnly))
+					Expect(fake.attached[release.ID]["user_group"]).To(Equal([]int{8}))
+				})
+			})
+		})
+
+		Context("when the release does not exist", func() {
+			BeforeEach(func() {
+				config.ReleaseID = 9999
+			})
+
+			It("returns an error", func() {
+				_, err := client.Releases.SetAvailability(config)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -0,0 +1,283 @@
+package pivnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+type CloneReleaseConfig struct {
+	ProductSlug     string
+	SourceReleaseID int
+	Version         string
+
+	// ReleaseType defaults to the source release's. ReleaseDate defaults to
+	// today, as for Create.
+	ReleaseType     string
+	ReleaseDate     string
+	Description     string
+	ReleaseNotesURL string
+
+	CopyUserGroups   bool
+	CopyFileGroups   bool
+	CopyDependencies bool
+	CopyUpgradePaths bool
+
+	// AddSourceAsUpgradePath makes the source release an upgrade path of the
+	// new release.
+	AddSourceAsUpgradePath bool
+
+	// CopyProductFiles re-attaches the source release's product files to the
+	// new release, except those in ExcludeProductFileIDs, which are typically
+	// files replaced in the new version.
+	CopyProductFiles      bool
+	ExcludeProductFileIDs []int
+}
+
+type ErrReleaseExists struct {
+	ProductSlug string `json:"product_slug" yaml:"product_slug"`
+	Version     string `json:"version" yaml:"version"`
+}
+
+func (e ErrReleaseExists) Error() string {
+	return fmt.Sprintf("release %s already exists for product %s", e.Version, e.ProductSlug)
+}
+
+// CloneReport records what Clone copied from the source release.
+type CloneReport struct {
+	ProductSlug   string  `json:"product_slug" yaml:"product_slug"`
+	SourceVersion string  `json:"source_version" yaml:"source_version"`
+	Release       Release `json:"release" yaml:"release"`
+
+	// Fields are the release fields copied from the source, with their values.
+	Fields []CopiedField `json:"fields,omitempty" yaml:"fields,omitempty"`
+
+	// Attached are the items attached to the new release, and Skipped the
+	// source release's product files that were excluded.
+	Attached []ClonedItem `json:"attached,omitempty" yaml:"attached,omitempty"`
+	Skipped  []ClonedItem `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+}
+
+type CopiedField struct {
+	Field string `json:"field" yaml:"field"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// ClonedItem is something attached to a release. Kind is one of the
+// PlanKind constants.
+type ClonedItem struct {
+	Kind string `json:"kind" yaml:"kind"`
+	ID   int    `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
+}
+
+// Clone creates a release with a new version from an existing one, copying
+// its EULA, ECCN, license exception and controlled flag, and the
+// associations the config selects. Like Create, the new release is Admins
+// Only.
+//
+// If attaching something fails, the release is left as it is and the report
+// records what was attached before the failure.
+func (r ReleasesService) Clone(config CloneReleaseConfig) (CloneReport, error) {
+	slug := config.ProductSlug
+
+	if config.Version == "" {
+		return CloneReport{}, fmt.Errorf("version must be set to clone a release")
+	}
+
+	releases, err := r.List(slug)
+	if err != nil {
+		return CloneReport{}, err
+	}
+
+	for _, release := range releases {
+		if release.Version == config.Version {
+			return CloneReport{}, ErrReleaseExists{ProductSlug: slug, Version: config.Version}
+		}
+	}
+
+	source, err := r.releaseContents(slug, config.SourceReleaseID)
+	if err != nil {
+		return CloneReport{}, err
+	}
+
+	createConfig := CreateReleaseConfig{
+		ProductSlug:      slug,
+		Version:          config.Version,
+		ReleaseType:      config.ReleaseType,
+		ReleaseDate:      config.ReleaseDate,
+		Description:      config.Description,
+		ReleaseNotesURL:  config.ReleaseNotesURL,
+		Controlled:       source.release.Controlled,
+		ECCN:             source.release.ECCN,
+		LicenseException: source.release.LicenseException,
+	}
+	if createConfig.ReleaseType == "" {
+		createConfig.ReleaseType = string(source.release.ReleaseType)
+	}
+	if source.release.EULA != nil {
+		createConfig.EULASlug = source.release.EULA.Slug
+	}
+
+	report := CloneReport{
+		ProductSlug:   slug,
+		SourceVersion: source.release.Version,
+	}
+
+	// Pivnet defaults releases to not controlled, so only true is copied
+	controlled := ""
+	if createConfig.Controlled {
+		controlled = "true"
+	}
+
+	for _, f := range [][2]string{
+		{"eula_slug", createConfig.EULASlug},
+		{"eccn", createConfig.ECCN},
+		{"license_exception", createConfig.LicenseException},
+		{"controlled", controlled},
+	} {
+		if f[1] != "" {
+			report.Fields = append(report.Fields, CopiedField{Field: f[0], Value: f[1]})
+		}
+	}
+
+	report.Release, err = r.Create(createConfig)
+	if err != nil {
+		return CloneReport{}, err
+	}
+
+	r.l.Info("Cloned release", logger.Data{
+		"source_version": source.release.Version,
+		"version":        report.Release.Version,
+	})
+
+	items, skipped := cloneItems(config, source)
+	report.Skipped = skipped
+
+	for _, item := range items {
+		err := r.attachClonedItem(slug, report.Release.ID, item)
+		if err != nil {
+			return report, fmt.Errorf("could not attach %s %s: %s", item.Kind, item.Name, err)
+		}
+
+		report.Attached = append(report.Attached, item)
+	}
+
+	return report, nil
+}
+
+// cloneItems lists what the config selects to attach to the new release and
+// the product files it excludes.
+func cloneItems(config CloneReleaseConfig, source releaseContents) ([]ClonedItem, []ClonedItem) {
+	var items, skipped []ClonedItem
+
+	if config.CopyUserGroups {
+		for _, g := range source.userGroups {
+			items = append(items, ClonedItem{Kind: PlanKindUserGroup, ID: g.ID, Name: g.Name})
+		}
+	}
+
+	if config.CopyFileGroups {
+		for _, g := range source.fileGroups {
+			items = append(items, ClonedItem{Kind: PlanKindFileGroup, ID: g.ID, Name: g.Name})
+		}
+	}
+
+	if config.CopyDependencies {
+		for _, d := range source.dependencies {
+			items = append(items, ClonedItem{
+				Kind: PlanKindDependency,
+				ID:   d.Release.ID,
+				Name: d.Release.Product.Slug + " " + d.Release.Version,
+			})
+		}
+	}
+
+	if config.CopyUpgradePaths {
+		for _, u := range source.upgradePaths {
+			items = append(items, ClonedItem{Kind: PlanKindUpgradePath, ID: u.Release.ID, Name: u.Release.Version})
+		}
+	}
+
+	if config.AddSourceAsUpgradePath {
+		items = append(items, ClonedItem{
+			Kind: PlanKindUpgradePath,
+			ID:   source.release.ID,
+			Name: source.release.Version,
+		})
+	}
+
+	if config.CopyProductFiles {
+		excluded := map[int]bool{}
+		for _, id := range config.ExcludeProductFileIDs {
+			excluded[id] = true
+		}
+
+		for _, pf := range source.productFiles {
+			item := ClonedItem{Kind: PlanKindProductFile, ID: pf.ID, Name: pf.Name}
+			if excluded[pf.ID] {
+				skipped = append(skipped, item)
+			} else {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items, skipped
+}
+
+func (r ReleasesService) attachClonedItem(productSlug string, releaseID int, item ClonedItem) error {
+	switch item.Kind {
+	case PlanKindUserGroup:
+		return UserGroupsService{client: r.client}.AddToRelease(productSlug, releaseID, item.ID)
+	case PlanKindFileGroup:
+		return FileGroupsService{client: r.client}.AddToRelease(productSlug, releaseID, item.ID)
+	case PlanKindDependency:
+		return ReleaseDependenciesService{client: r.client}.Add(productSlug, releaseID, item.ID)
+	case PlanKindUpgradePath:
+		return ReleaseUpgradePathsService{client: r.client}.Add(productSlug, releaseID, item.ID)
+	case PlanKindProductFile:
+		return ProductFilesService{client: r.client}.AddToRelease(productSlug, releaseID, item.ID)
+	}
+
+	return fmt.Errorf("cannot attach %s", item.Kind)
+}
+
+// Write writes the report as text or JSON.
+func (c CloneReport) Write(w io.Writer, format OutputFormat) error {
+	switch format {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(c)
+	case OutputFormatText:
+		_, err := io.WriteString(w, c.text())
+		return err
+	}
+
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+func (c CloneReport) text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s cloned from %s (release %d)\n",
+		c.ProductSlug, c.Release.Version, c.SourceVersion, c.Release.ID)
+
+	for _, f := range c.Fields {
+		fmt.Fprintf(&b, "  copied %s: %s\n", f.Field, f.Value)
+	}
+
+	for _, item := range c.Attached {
+		fmt.Fprintf(&b, "  attached %s %s\n", item.Kind, item.Name)
+	}
+
+	for _, item := range c.Skipped {
+		fmt.Fprintf(&b, "  skipped %s %s\n", item.Kind, item.Name)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,206 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release clone", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		fake   *fakePivnet
+		config pivnet.CloneReleaseConfig
+
+		olderRelease pivnet.Release
+		source       pivnet.Release
+		stemcell     pivnet.Release
+		tile         pivnet.ProductFile
+		docs         pivnet.ProductFile
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		fake = newFakePivnet()
+		fake.routeFrom(server)
+
+		olderRelease = fake.addRelease(productSlug, pivnet.Release{Version: "1.0.0"})
+		source = fake.addRelease(productSlug, pivnet.Release{
+			Version:          "1.0.1",
+			ReleaseType:      "Security Release",
+			EULA:             &pivnet.EULA{Slug: "some-eula"},
+			ECCN:             "5D002",
+			LicenseException: "TSU",
+			Description:      "not copied",
+		})
+		stemcell = fake.addRelease("stemcells", pivnet.Release{Version: "3000.1"})
+
+		tile = fake.addProductFile(productSlug, pivnet.ProductFile{Name: "Tile"})
+		docs = fake.addProductFile(productSlug, pivnet.ProductFile{Name: "Docs"})
+
+		fake.fileGroups[productSlug] = []pivnet.FileGroup{{ID: 7, Name: "Stemcells"}}
+		fake.userGroups = []pivnet.UserGroup{{ID: 8, Name: "Partners"}}
+
+		fake.attach(source.ID, "product_file", tile.ID)
+		fake.attach(source.ID, "product_file", docs.ID)
+		fake.attach(source.ID, "file_group", 7)
+		fake.attach(source.ID, "user_group", 8)
+		fake.attach(source.ID, "dependency", stemcell.ID)
+		fake.attach(source.ID, "upgrade_path", olderRelease.ID)
+
+		config = pivnet.CloneReleaseConfig{
+			ProductSlug:     productSlug,
+			SourceReleaseID: source.ID,
+			Version:         "1.0.2",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("creates the release with the source's fields and nothing attached", func() {
+		report, err := client.Releases.Clone(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.SourceVersion).To(Equal("1.0.1"))
+		Expect(report.Release.Version).To(Equal("1.0.2"))
+		Expect(report.Release.ReleaseType).To(Equal(pivnet.ReleaseType("Security Release")))
+		Expect(report.Release.EULA).To(Equal(&pivnet.EULA{Slug: "some-eula"}))
+		Expect(report.Release.ECCN).To(Equal("5D002"))
+		Expect(report.Release.LicenseException).To(Equal("TSU"))
+		Expect(report.Release.Description).To(BeEmpty())
+
+		Expect(report.Fields).To(Equal([]pivnet.CopiedField{
+			{Field: "eula_slug", Value: "some-eula"},
+			{Field: "eccn", Value: "5D002"},
+			{Field: "license_exception", Value: "TSU"},
+		}))
+		Expect(report.Attached).To(BeEmpty())
+		Expect(fake.attached[report.Release.ID]).To(BeEmpty())
+	})
+
+	Context("when the source release is controlled", func() {
+		BeforeEach(func() {
+			controlled := fake.addRelease(productSlug, pivnet.Release{Version: "1.0.1-controlled", Controlled: true})
+			config.SourceReleaseID = controlled.ID
+		})
+
+		It("copies and reports the controlled flag", func() {
+			report, err := client.Releases.Clone(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Release.Controlled).To(BeTrue())
+			Expect(report.Fields).To(Equal([]pivnet.CopiedField{
+				{Field: "controlled", Value: "true"},
+			}))
+		})
+	})
+
+	Context("when associations are selected", func() {
+		BeforeEach(func() {
+			config.ReleaseType = "Maintenance Release"
+			config.CopyUserGroups = true
+			config.CopyFileGroups = true
+			config.CopyDependencies = true
+			config.CopyUpgradePaths = true
+			config.AddSourceAsUpgradePath = true
+			config.CopyProductFiles = true
+			config.ExcludeProductFileIDs = []int{tile.ID}
+		})
+
+		It("copies them and reports what was copied", func() {
+			report, err := client.Releases.Clone(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Release.ReleaseType).To(Equal(pivnet.ReleaseType("Maintenance Release")))
+
+			Expect(report.Attached).To(Equal([]pivnet.ClonedItem{
+				{Kind: pivnet.PlanKindUserGroup, ID: 8, Name: "Partners"},
+				{Kind: pivnet.PlanKindFileGroup, ID: 7, Name: "Stemcells"},
+				{Kind: pivnet.PlanKindDependency, ID: stemcell.ID, Name: "stemcells 3000.1"},
+				{Kind: pivnet.PlanKindUpgradePath, ID: olderRelease.ID, Name: "1.0.0"},
+				{Kind: pivnet.PlanKindUpgradePath, ID: source.ID, Name: "1.0.1"},
+				{Kind: pivnet.PlanKindProductFile, ID: docs.ID, Name: "Docs"},
+			}))
+			Expect(report.Skipped).To(Equal([]pivnet.ClonedItem{
+				{Kind: pivnet.PlanKindProductFile, ID: tile.ID, Name: "Tile"},
+			}))
+
+			attached := fake.attached[report.Release.ID]
+			Expect(attached["user_group"]).To(Equal([]int{8}))
+			Expect(attached["file_group"]).To(Equal([]int{7}))
+			Expect(attached["dependency"]).To(Equal([]int{stemcell.ID}))
+			Expect(attached["upgrade_path"]).To(Equal([]int{olderRelease.ID, source.ID}))
+			Expect(attached["product_file"]).To(Equal([]int{docs.ID}))
+		})
+
+		It("writes the report", func() {
+			report, err := client.Releases.Clone(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			var b bytes.Buffer
+			Expect(report.Write(&b, pivnet.OutputFormatText)).To(Succeed())
+			Expect(b.String()).To(ContainSubstring("cloned from 1.0.1"))
+			Expect(b.String()).To(ContainSubstring("  copied eccn: 5D002\n"))
+			Expect(b.String()).To(ContainSubstring("  attached dependency stemcells 3000.1\n"))
+			Expect(b.String()).To(ContainSubstring("  skipped product_file Tile\n"))
+
+			b.Reset()
+			Expect(report.Write(&b, pivnet.OutputFormatJSON)).To(Succeed())
+
+			var decoded pivnet.CloneReport
+			Expect(json.Unmarshal(b.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded.Attached).To(Equal(report.Attached))
+		})
+	})
+
+	Context("when the version already exists", func() {
+		BeforeEach(func() {
+			config.Version = "1.0.0"
+		})
+
+		It("returns an ErrReleaseExists without creating anything", func() {
+			_, err := client.Releases.Clone(config)
+			Expect(err).To(MatchError(pivnet.ErrReleaseExists{ProductSlug: productSlug, Version: "1.0.0"}))
+			Expect(fake.writeCount()).To(BeZero())
+		})
+	})
+
+	Context("when the version is missing", func() {
+		BeforeEach(func() {
+			config.Version = ""
+		})
+
+		It("returns an error", func() {
+			_, err := client.Releases.Clone(config)
+			Expect(err).To(MatchError("version must be set to clone a release"))
+		})
+	})
+})
@@ -0,0 +1,451 @@
+package pivnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+type ReleaseDiffConfig struct {
+	ProductSlug   string
+	FromReleaseID int
+	ToReleaseID   int
+}
+
+// ReleaseDiff is what changed from one release of a product to another.
+type ReleaseDiff struct {
+	ProductSlug  string        `json:"product_slug" yaml:"product_slug"`
+	FromVersion  string        `json:"from_version" yaml:"from_version"`
+	ToVersion    string        `json:"to_version" yaml:"to_version"`
+	Fields       []FieldChange `json:"fields" yaml:"fields"`
+	ProductFiles []ItemChange  `json:"product_files" yaml:"product_files"`
+	FileGroups   []ItemChange  `json:"file_groups" yaml:"file_groups"`
+	UserGroups   []ItemChange  `json:"user_groups" yaml:"user_groups"`
+	Dependencies []ItemChange  `json:"dependencies" yaml:"dependencies"`
+	UpgradePaths []ItemChange  `json:"upgrade_paths" yaml:"upgrade_paths"`
+}
+
+type FieldChange struct {
+	Field string `json:"field" yaml:"field"`
+	From  string `json:"from" yaml:"from"`
+	To    string `json:"to" yaml:"to"`
+}
+
+// ItemChange is an item added to, removed from or changed between the
+// releases. Changed items list their changed fields and, for file groups,
+// the changes to the product files they contain.
+type ItemChange struct {
+	Change string        `json:"change" yaml:"change"`
+	Name   string        `json:"name" yaml:"name"`
+	Fields []FieldChange `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Items  []ItemChange  `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// releaseContents is everything about a release that is compared.
+type releaseContents struct {
+	release      Release
+	productFiles []ProductFile
+	fileGroups   []FileGroup
+	userGroups   []UserGroup
+	dependencies []ReleaseDependency
+	upgradePaths []ReleaseUpgradePath
+}
+
+// Diff compares two releases of a product: their metadata, product files,
+// file groups and their contents, user groups, dependencies and upgrade
+// paths.
+func (r ReleasesService) Diff(config ReleaseDiffConfig) (ReleaseDiff, error) {
+	releaseIDs := []int{config.FromReleaseID, config.ToReleaseID}
+	contents := make([]releaseContents, len(releaseIDs))
+
+	err := forEachConcurrently(len(releaseIDs), len(releaseIDs), func(i int) error {
+		var err error
+		contents[i], err = r.releaseContents(config.ProductSlug, releaseIDs[i])
+		return err
+	})
+	if err != nil {
+		return ReleaseDiff{}, err
+	}
+
+	from, to := contents[0], contents[1]
+
+	return ReleaseDiff{
+		ProductSlug:  config.ProductSlug,
+		FromVersion:  from.release.Version,
+		ToVersion:    to.release.Version,
+		Fields:       diffFields(releaseFields(from.release), releaseFields(to.release)),
+		ProductFiles: diffProductFiles(from.productFiles, to.productFiles),
+		FileGroups:   diffFileGroups(from.fileGroups, to.fileGroups),
+		UserGroups:   diffItems(userGroupItems(from.userGroups), userGroupItems(to.userGroups)),
+		Dependencies: diffItems(dependencyItems(from.dependencies), dependencyItems(to.dependencies)),
+		UpgradePaths: diffItems(upgradePathItems(from.upgradePaths), upgradePathItems(to.upgradePaths)),
+	}, nil
+}
+
+func (r ReleasesService) releaseContents(productSlug string, releaseID int) (releaseContents, error) {
+	var c releaseContents
+	var err error
+
+	c.release, err = r.Get(productSlug, releaseID)
+	if err != nil {
+		return c, err
+	}
+
+	c.productFiles, err = ProductFilesService{client: r.client}.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return c, err
+	}
+
+	c.fileGroups, err = FileGroupsService{client: r.client}.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return c, err
+	}
+
+	c.userGroups, err = UserGroupsService{client: r.client}.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return c, err
+	}
+
+	c.dependencies, err = ReleaseDependenciesService{client: r.client}.List(productSlug, releaseID)
+	if err != nil {
+		return c, err
+	}
+
+	c.upgradePaths, err = ReleaseUpgradePathsService{client: r.client}.Get(productSlug, releaseID)
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// Empty reports whether the releases differ in nothing but their versions.
+func (d ReleaseDiff) Empty() bool {
+	for _, f := range d.Fields {
+		if f.Field != "version" {
+			return false
+		}
+	}
+
+	return len(d.ProductFiles) == 0 &&
+		len(d.FileGroups) == 0 &&
+		len(d.UserGroups) == 0 &&
+		len(d.Dependencies) == 0 &&
+		len(d.UpgradePaths) == 0
+}
+
+// namedFields is an item's name and its compared fields in a stable order.
+type namedFields struct {
+	name   string
+	fields [][2]string
+}
+
+func releaseFields(r Release) [][2]string {
+	eula := ""
+	if r.EULA != nil {
+		eula = r.EULA.Slug
+	}
+
+	return [][2]string{
+		{"version", r.Version},
+		{"release_type", string(r.ReleaseType)},
+		{"release_date", r.ReleaseDate},
+		{"availability", string(r.Availability)},
+		{"eula", eula},
+		{"description", r.Description},
+		{"release_notes_url", r.ReleaseNotesURL},
+		{"oss_compliant", r.OSSCompliant},
+		{"controlled", fmt.Sprintf("%t", r.Controlled)},
+		{"eccn", r.ECCN},
+		{"license_exception", r.LicenseException},
+		{"end_of_support_date", r.EndOfSupportDate},
+		{"end_of_guidance_date", r.EndOfGuidanceDate},
+		{"end_of_availability_date", r.EndOfAvailabilityDate},
+	}
+}
+
+func diffFields(from [][2]string, to [][2]string) []FieldChange {
+	var changes []FieldChange
+	for i := range from {
+		if from[i][1] != to[i][1] {
+			changes = append(changes, FieldChange{Field: from[i][0], From: from[i][1], To: to[i][1]})
+		}
+	}
+
+	return changes
+}
+
+func productFileItems(productFiles []ProductFile) []namedFields {
+	var items []namedFields
+	for _, pf := range productFiles {
+		items = append(items, namedFields{
+			name: pf.Name,
+			fields: [][2]string{
+				{"file_version", pf.FileVersion},
+				{"md5", pf.MD5},
+				{"size", fmt.Sprintf("%d", pf.Size)},
+			},
+		})
+	}
+
+	return items
+}
+
+func userGroupItems(userGroups []UserGroup) []namedFields {
+	var items []namedFields
+	for _, g := range userGroups {
+		items = append(items, namedFields{name: g.Name})
+	}
+
+	return items
+}
+
+// dependencyItems identifies dependencies by product, so that a dependency
+// on a newer release of the same product shows as a changed version.
+func dependencyItems(dependencies []ReleaseDependency) []namedFields {
+	var items []namedFields
+	for _, d := range dependencies {
+		items = append(items, namedFields{
+			name:   d.Release.Product.Slug,
+			fields: [][2]string{{"version", d.Release.Version}},
+		})
+	}
+
+	return items
+}
+
+func upgradePathItems(upgradePaths []ReleaseUpgradePath) []namedFields {
+	var items []namedFields
+	for _, u := range upgradePaths {
+		items = append(items, namedFields{name: u.Release.Version})
+	}
+
+	return items
+}
+
+func diffProductFiles(from []ProductFile, to []ProductFile) []ItemChange {
+	return diffItems(productFileItems(from), productFileItems(to))
+}
+
+func diffFileGroups(from []FileGroup, to []FileGroup) []ItemChange {
+	fromByName := map[string]FileGroup{}
+	for _, g := range from {
+		fromByName[g.Name] = g
+	}
+
+	toByName := map[string]FileGroup{}
+	for _, g := range to {
+		toByName[g.Name] = g
+	}
+
+	var changes []ItemChange
+	for _, g := range from {
+		if _, ok := toByName[g.Name]; !ok {
+			changes = append(changes, ItemChange{Change: ChangeRemoved, Name: g.Name})
+		}
+	}
+
+	for _, g := range to {
+		old, ok := fromByName[g.Name]
+		if !ok {
+			changes = append(changes, ItemChange{Change: ChangeAdded, Name: g.Name})
+			continue
+		}
+
+		items := diffProductFiles(old.ProductFiles, g.ProductFiles)
+		if len(items) > 0 {
+			changes = append(changes, ItemChange{Change: ChangeChanged, Name: g.Name, Items: items})
+		}
+	}
+
+	sortItemChanges(changes)
+
+	return changes
+}
+
+// diffItems matches items by name. Items whose names repeat are matched in
+// order.
+func diffItems(from []namedFields, to []namedFields) []ItemChange {
+	fromByName := map[string][]namedFields{}
+	for _, item := range from {
+		fromByName[item.name] = append(fromByName[item.name], item)
+	}
+
+	var changes []ItemChange
+	for _, item := range to {
+		olds := fromByName[item.name]
+		if len(olds) == 0 {
+			changes = append(changes, ItemChange{Change: ChangeAdded, Name: item.name})
+			continue
+		}
+
+		old := olds[0]
+		fromByName[item.name] = olds[1:]
+
+		fields := diffFields(old.fields, item.fields)
+		if len(fields) > 0 {
+			changes = append(changes, ItemChange{Change: ChangeChanged, Name: item.name, Fields: fields})
+		}
+	}
+
+	for _, item := range from {
+		if len(fromByName[item.name]) > 0 {
+			fromByName[item.name] = fromByName[item.name][1:]
+			changes = append(changes, ItemChange{Change: ChangeRemoved, Name: item.name})
+		}
+	}
+
+	sortItemChanges(changes)
+
+	return changes
+}
+
+func sortItemChanges(changes []ItemChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+}
+
+// Write writes the diff as text, JSON or Markdown.
+func (d ReleaseDiff) Write(w io.Writer, format OutputFormat) error {
+	switch format {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(d)
+	case OutputFormatText:
+		_, err := io.WriteString(w, d.text())
+		return err
+	case OutputFormatMarkdown:
+		_, err := io.WriteString(w, d.markdown())
+		return err
+	}
+
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+func (d ReleaseDiff) sections() []struct {
+	title   string
+	changes []ItemChange
+} {
+	return []struct {
+		title   string
+		changes []ItemChange
+	}{
+		{"Product files", d.ProductFiles},
+		{"File groups", d.FileGroups},
+		{"User groups", d.UserGroups},
+		{"Dependencies", d.Dependencies},
+		{"Upgrade paths", d.UpgradePaths},
+	}
+}
+
+var changeSymbols = map[string]string{
+	ChangeAdded:   "+",
+	ChangeRemoved: "-",
+	ChangeChanged: "~",
+}
+
+func (d ReleaseDiff) text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s -> %s\n", d.ProductSlug, d.FromVersion, d.ToVersion)
+
+	if d.Empty() {
+		b.WriteString("No changes\n")
+		return b.String()
+	}
+
+	if len(d.Fields) > 0 {
+		b.WriteString("\nRelease:\n")
+		for _, f := range d.Fields {
+			fmt.Fprintf(&b, "  ~ %s: %q -> %q\n", f.Field, f.From, f.To)
+		}
+	}
+
+	for _, section := range d.sections() {
+		if len(section.changes) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n%s:\n", section.title)
+		writeTextChanges(&b, section.changes, "  ")
+	}
+
+	return b.String()
+}
+
+func writeTextChanges(b *strings.Builder, changes []ItemChange, indent string) {
+	for _, c := range changes {
+		fmt.Fprintf(b, "%s%s %s\n", indent, changeSymbols[c.Change], c.Name)
+
+		for _, f := range c.Fields {
+			fmt.Fprintf(b, "%s    %s: %q -> %q\n", indent, f.Field, f.From, f.To)
+		}
+
+		writeTextChanges(b, c.Items, indent+"    ")
+	}
+}
+
+func (d ReleaseDiff) markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "## %s %s → %s\n", d.ProductSlug, d.FromVersion, d.ToVersion)
+
+	if d.Empty() {
+		b.WriteString("\nNo changes.\n")
+		return b.String()
+	}
+
+	if len(d.Fields) > 0 {
+		b.WriteString("\n### Release\n\n| Field | From | To |\n| --- | --- | --- |\n")
+		for _, f := range d.Fields {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", f.Field, markdownCell(f.From), markdownCell(f.To))
+		}
+	}
+
+	for _, section := range d.sections() {
+		if len(section.changes) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n### %s\n\n", section.title)
+		writeMarkdownChanges(&b, section.changes, "")
+	}
+
+	return b.String()
+}
+
+func writeMarkdownChanges(b *strings.Builder, changes []ItemChange, indent string) {
+	for _, c := range changes {
+		fmt.Fprintf(b, "%s- **%s** %s\n", indent, c.Change, markdownCell(c.Name))
+
+		for _, f := range c.Fields {
+			fmt.Fprintf(b, "%s  - %s: %s → %s\n", indent, f.Field, markdownCode(f.From), markdownCode(f.To))
+		}
+
+		writeMarkdownChanges(b, c.Items, indent+"  ")
+	}
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return "_none_"
+	}
+
+	return "`" + strings.Replace(s, "`", "'", -1) + "`"
+}
+
+// markdownCell escapes characters that would break a table or list item.
+func markdownCell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(s, "\n", " ", -1)
+}
@@ -0,0 +1,232 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release diff", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		config pivnet.ReleaseDiffConfig
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		config = pivnet.ReleaseDiffConfig{
+			ProductSlug:   productSlug,
+			FromReleaseID: 1,
+			ToReleaseID:   2,
+		}
+
+		routeRelease := func(
+			release pivnet.Release,
+			productFiles []pivnet.ProductFile,
+			fileGroups []pivnet.FileGroup,
+			userGroups []pivnet.UserGroup,
+			dependencies []pivnet.ReleaseDependency,
+			upgradePaths []pivnet.ReleaseUpgradePath,
+		) {
+			releasePath := fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, release.ID)
+
+			server.RouteToHandler("GET", releasePath,
+				ghttp.RespondWithJSONEncoded(http.StatusOK, release))
+			server.RouteToHandler("GET", releasePath+"/product_files",
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles}))
+			server.RouteToHandler("GET", releasePath+"/file_groups",
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: fileGroups}))
+			server.RouteToHandler("GET", releasePath+"/user_groups",
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.UserGroupsResponse{UserGroups: userGroups}))
+			server.RouteToHandler("GET", releasePath+"/dependencies",
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleaseDependenciesResponse{ReleaseDependencies: dependencies}))
+			server.RouteToHandler("GET", releasePath+"/upgrade_paths",
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleaseUpgradePathsResponse{ReleaseUpgradePaths: upgradePaths}))
+		}
+
+		stemcell := func(version string) pivnet.ReleaseDependency {
+			return pivnet.ReleaseDependency{Release: pivnet.DependentRelease{
+				Version: version,
+				Product: pivnet.Product{Slug: "stemcells"},
+			}}
+		}
+
+		routeRelease(
+			pivnet.Release{ID: 1, Version: "1.0.0", ReleaseType: "Major Release", EULA: &pivnet.EULA{Slug: "some-eula"}},
+			[]pivnet.ProductFile{
+				{Name: "tile", FileVersion: "1.0.0", MD5: "aaa", Size: 10},
+				{Name: "docs", FileVersion: "1.0.0", MD5: "bbb", Size: 20},
+			},
+			[]pivnet.FileGroup{
+				{Name: "Stemcells", ProductFiles: []pivnet.ProductFile{{Name: "stemcell-a"}}},
+				{Name: "Old group"},
+			},
+			[]pivnet.UserGroup{{Name: "Partners"}},
+			[]pivnet.ReleaseDependency{stemcell("3000.1")},
+			nil,
+		)
+
+		routeRelease(
+			pivnet.Release{ID: 2, Version: "1.1.0", ReleaseType: "Minor Release", EULA: &pivnet.EULA{Slug: "some-eula"}},
+			[]pivnet.ProductFile{
+				{Name: "tile", FileVersion: "1.1.0", MD5: "ccc", Size: 10},
+				{Name: "docs", FileVersion: "1.0.0", MD5: "bbb", Size: 20},
+				{Name: "cli", FileVersion: "1.1.0", MD5: "ddd", Size: 5},
+			},
+			[]pivnet.FileGroup{
+				{Name: "Stemcells", ProductFiles: []pivnet.ProductFile{{Name: "stemcell-a"}, {Name: "stemcell-b"}}},
+			},
+			[]pivnet.UserGroup{{Name: "Partners"}},
+			[]pivnet.ReleaseDependency{stemcell("3000.2")},
+			[]pivnet.ReleaseUpgradePath{{Release: pivnet.UpgradePathRelease{ID: 1, Version: "1.0.0"}}},
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Diff", func() {
+		It("compares metadata, product files, groups, dependencies and upgrade paths", func() {
+			diff, err := client.Releases.Diff(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(diff.FromVersion).To(Equal("1.0.0"))
+			Expect(diff.ToVersion).To(Equal("1.1.0"))
+			Expect(diff.Empty()).To(BeFalse())
+
+			Expect(diff.Fields).To(Equal([]pivnet.FieldChange{
+				{Field: "version", From: "1.0.0", To: "1.1.0"},
+				{Field: "release_type", From: "Major Release", To: "Minor Release"},
+			}))
+
+			Expect(diff.ProductFiles).To(Equal([]pivnet.ItemChange{
+				{Change: pivnet.ChangeAdded, Name: "cli"},
+				{Change: pivnet.ChangeChanged, Name: "tile", Fields: []pivnet.FieldChange{
+					{Field: "file_version", From: "1.0.0", To: "1.1.0"},
+					{Field: "md5", From: "aaa", To: "ccc"},
+				}},
+			}))
+
+			Expect(diff.FileGroups).To(Equal([]pivnet.ItemChange{
+				{Change: pivnet.ChangeRemoved, Name: "Old group"},
+				{Change: pivnet.ChangeChanged, Name: "Stemcells", Items: []pivnet.ItemChange{
+					{Change: pivnet.ChangeAdded, Name: "stemcell-b"},
+				}},
+			}))
+
+			Expect(diff.UserGroups).To(BeEmpty())
+
+			Expect(diff.Dependencies).To(Equal([]pivnet.ItemChange{
+				{Change: pivnet.ChangeChanged, Name: "stemcells", Fields: []pivnet.FieldChange{
+					{Field: "version", From: "3000.1", To: "3000.2"},
+				}},
+			}))
+
+			Expect(diff.UpgradePaths).To(Equal([]pivnet.ItemChange{
+				{Change: pivnet.ChangeAdded, Name: "1.0.0"},
+			}))
+		})
+
+		Context("when comparing a release with itself", func() {
+			BeforeEach(func() {
+				config.ToReleaseID = 1
+			})
+
+			It("is empty", func() {
+				diff, err := client.Releases.Diff(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diff.Empty()).To(BeTrue())
+			})
+		})
+
+		Context("when a release cannot be fetched", func() {
+			BeforeEach(func() {
+				server.RouteToHandler("GET",
+					fmt.Sprintf("%s/products/%s/releases/%d/user_groups", apiPrefix, productSlug, 2),
+					ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+				)
+			})
+
+			It("returns an error", func() {
+				_, err := client.Releases.Diff(config)
+				Expect(err.Error()).To(ContainSubstring("foo message"))
+			})
+		})
+	})
+
+	Describe("Write", func() {
+		var diff pivnet.ReleaseDiff
+
+		BeforeEach(func() {
+			var err error
+			diff, err = client.Releases.Diff(config)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("writes text", func() {
+			var b bytes.Buffer
+			Expect(diff.Write(&b, pivnet.OutputFormatText)).To(Succeed())
+
+			Expect(b.String()).To(HavePrefix(productSlug + " 1.0.0 -> 1.1.0\n"))
+			Expect(b.String()).To(ContainSubstring("  ~ release_type: \"Major Release\" -> \"Minor Release\"\n"))
+			Expect(b.String()).To(ContainSubstring("Product files:\n  + cli\n  ~ tile\n      file_version: \"1.0.0\" -> \"1.1.0\"\n"))
+			Expect(b.String()).To(ContainSubstring("File groups:\n  - Old group\n  ~ Stemcells\n      + stemcell-b\n"))
+		})
+
+		It("writes JSON", func() {
+			var b bytes.Buffer
+			Expect(diff.Write(&b, pivnet.OutputFormatJSON)).To(Succeed())
+
+			var decoded pivnet.ReleaseDiff
+			Expect(json.Unmarshal(b.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded).To(Equal(diff))
+		})
+
+		It("writes Markdown", func() {
+			var b bytes.Buffer
+			Expect(diff.Write(&b, pivnet.OutputFormatMarkdown)).To(Succeed())
+
+			Expect(b.String()).To(HavePrefix(fmt.Sprintf("## %s 1.0.0 → 1.1.0\n", productSlug)))
+			Expect(b.String()).To(ContainSubstring("| release_type | Major Release | Minor Release |\n"))
+			Expect(b.String()).To(ContainSubstring("### Product files\n\n- **added** cli\n- **changed** tile\n  - file_version: `1.0.0` → `1.1.0`\n"))
+		})
+
+		It("rejects unknown formats", func() {
+			Expect(diff.Write(&bytes.Buffer{}, "yaml")).NotTo(Succeed())
+		})
+
+		It("rejects CSV, which only EOL reports support", func() {
+			Expect(diff.Write(&bytes.Buffer{}, pivnet.OutputFormatCSV)).To(
+				MatchError("unsupported output format: csv"))
+		})
+	})
+})
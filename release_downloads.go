@@ -0,0 +1,253 @@
+package pivnet
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+type DownloadReleaseConfig struct {
+	ProductSlug string
+	ReleaseID   int
+
+	// Dir is the existing directory matching files are written to.
+	Dir string
+
+	// Globs are matched against the file name of each product file, i.e. the
+	// base name of its AWS object key. Every glob must match at least one
+	// product file. No globs matches every product file.
+	Globs []string
+
+	// FileTypes restricts the download to product files of the given types,
+	// e.g. FileTypeSoftware, ignoring case. No file types matches every
+	// product file.
+	FileTypes []string
+
+	// Platforms restricts the download to product files supporting at least
+	// one of the given platforms, ignoring case. No platforms matches every
+	// product file.
+	Platforms []string
+
+	// Concurrency is the number of files downloaded in parallel.
+	// It defaults to 4.
+	Concurrency int
+
+	Options DownloadOptions
+}
+
+type DownloadedFile struct {
+	ProductFile ProductFile    `json:"product_file" yaml:"product_file"`
+	Path        string         `json:"path" yaml:"path"`
+	Result      DownloadResult `json:"result" yaml:"result"`
+}
+
+type ErrNoGlobMatch struct {
+	Glob string
+}
+
+func (e ErrNoGlobMatch) Error() string {
+	return fmt.Sprintf("no product files match glob: %s", e.Glob)
+}
+
+// FileName returns the name the product file is stored as on disk,
+// which is the base name of its AWS object key.
+func (p ProductFile) FileName() string {
+	return path.Base(p.AWSObjectKey)
+}
+
+// DownloadRelease downloads every product file of a release, including those
+// in its file groups, that matches the config's filters into config.Dir.
+func (p ProductFilesService) DownloadRelease(config DownloadReleaseConfig) ([]DownloadedFile, error) {
+	productFiles, err := p.releaseProductFiles(config.ProductSlug, config.ReleaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	matched, err := filterProductFiles(productFiles, config)
+	if err != nil {
+		return nil, err
+	}
+
+	p.client.logger.Debug("Downloading release", logger.Data{
+		"productSlug":  config.ProductSlug,
+		"releaseID":    config.ReleaseID,
+		"productFiles": len(matched),
+	})
+
+	downloaded, err := downloadConcurrently(len(matched), config.Concurrency, func(i int) (DownloadedFile, error) {
+		return p.downloadToDir(config, matched[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Options.Lockfile != nil {
+		err = p.recordRelease(config.Options.Lockfile, config.ProductSlug, config.ReleaseID, downloaded)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return downloaded, nil
+}
+
+func (p ProductFilesService) downloadToDir(
+	config DownloadReleaseConfig,
+	productFile ProductFile,
+) (DownloadedFile, error) {
+	filePath := filepath.Join(config.Dir, productFile.FileName())
+
+	result, err := p.downloadToPath(
+		filePath,
+		config.ProductSlug,
+		config.ReleaseID,
+		productFile,
+		config.Options,
+		nil,
+	)
+	if err != nil {
+		return DownloadedFile{}, err
+	}
+
+	return DownloadedFile{
+		ProductFile: productFile,
+		Path:        filePath,
+		Result:      result,
+	}, nil
+}
+
+// releaseProductFiles returns the product files attached directly to a
+// release and those in its file groups, each exactly once.
+func (p ProductFilesService) releaseProductFiles(productSlug string, releaseID int) ([]ProductFile, error) {
+	productFiles, err := p.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileGroups := FileGroupsService{client: p.client}
+	groups, err := fileGroups.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		productFiles = append(productFiles, group.ProductFiles...)
+	}
+
+	seen := map[int]bool{}
+	var unique []ProductFile
+
+	for _, pf := range productFiles {
+		if seen[pf.ID] {
+			continue
+		}
+		seen[pf.ID] = true
+
+		// File group listings only carry a summary of each product file
+		if pf.AWSObjectKey == "" || pf.Links == nil {
+			pf, err = p.GetForRelease(productSlug, releaseID, pf.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		unique = append(unique, pf)
+	}
+
+	sort.Sort(productFilesByID(unique))
+
+	return unique, nil
+}
+
+func filterProductFiles(productFiles []ProductFile, config DownloadReleaseConfig) ([]ProductFile, error) {
+	var candidates []ProductFile
+	for _, pf := range productFiles {
+		if !matchesFileTypesAndPlatforms(pf, config.FileTypes, config.Platforms) {
+			continue
+		}
+
+		candidates = append(candidates, pf)
+	}
+
+	if len(config.Globs) == 0 {
+		return candidates, checkFileNames(candidates)
+	}
+
+	matched := map[int]bool{}
+	for _, glob := range config.Globs {
+		found := false
+
+		for _, pf := range candidates {
+			ok, err := filepath.Match(glob, pf.FileName())
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				found = true
+				matched[pf.ID] = true
+			}
+		}
+
+		if !found {
+			return nil, ErrNoGlobMatch{Glob: glob}
+		}
+	}
+
+	var filtered []ProductFile
+	for _, pf := range candidates {
+		if matched[pf.ID] {
+			filtered = append(filtered, pf)
+		}
+	}
+
+	return filtered, checkFileNames(filtered)
+}
+
+// checkFileNames checks that each product file can be written to a
+// directory under its own file name.
+func checkFileNames(productFiles []ProductFile) error {
+	names := map[string]int{}
+	for _, pf := range productFiles {
+		name := pf.FileName()
+		if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf(
+				"product file %d has no usable file name: AWS object key %q",
+				pf.ID,
+				pf.AWSObjectKey,
+			)
+		}
+
+		if id, ok := names[pf.FileName()]; ok {
+			return fmt.Errorf(
+				"product files %d and %d have the same file name: %s",
+				id,
+				pf.ID,
+				pf.FileName(),
+			)
+		}
+		names[pf.FileName()] = pf.ID
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+type productFilesByID []ProductFile
+
+func (p productFilesByID) Len() int           { return len(p) }
+func (p productFilesByID) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p productFilesByID) Less(i, j int) bool { return p[i].ID < p[j].ID }
@@ -0,0 +1,301 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release downloads", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		releaseID int
+		dir       string
+		config    pivnet.DownloadReleaseConfig
+
+		productFiles []pivnet.ProductFile
+		fileGroups   []pivnet.FileGroup
+	)
+
+	productFile := func(id int, key string, fileType string, platforms ...string) pivnet.ProductFile {
+		return pivnet.ProductFile{
+			ID:           id,
+			AWSObjectKey: key,
+			FileType:     fileType,
+			Platforms:    platforms,
+			Links: &pivnet.Links{
+				Download: map[string]string{
+					"href": fmt.Sprintf("/products/%s/releases/%d/product_files/%d/download", productSlug, releaseID, id),
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		releaseID = 12
+
+		var err error
+		dir, err = ioutil.TempDir("", "release-downloads")
+		Expect(err).NotTo(HaveOccurred())
+
+		config = pivnet.DownloadReleaseConfig{
+			ProductSlug: productSlug,
+			ReleaseID:   releaseID,
+			Dir:         dir,
+		}
+
+		productFiles = []pivnet.ProductFile{
+			productFile(1, "product-files/p/tile.pivotal", pivnet.FileTypeSoftware, "Linux"),
+			productFile(2, "product-files/p/notes.pdf", pivnet.FileTypeDocumentation),
+			productFile(3, "product-files/p/cli-windows.zip", pivnet.FileTypeSoftware, "Windows"),
+		}
+
+		fileGroups = []pivnet.FileGroup{
+			{
+				ID:   7,
+				Name: "stemcells",
+				ProductFiles: []pivnet.ProductFile{
+					{ID: 4},
+					{ID: 1},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles}),
+		)
+
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases/%d/file_groups", apiPrefix, productSlug, releaseID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: fileGroups}),
+		)
+
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/4", apiPrefix, productSlug, releaseID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+				ProductFile: productFile(4, "product-files/p/stemcell.tgz", pivnet.FileTypeSoftware, "Linux"),
+			}),
+		)
+
+		for id := 1; id <= 4; id++ {
+			server.RouteToHandler("POST",
+				fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d/download", apiPrefix, productSlug, releaseID, id),
+				ghttp.RespondWith(http.StatusOK, fmt.Sprintf("contents of %d", id)),
+			)
+		}
+	})
+
+	downloadedNames := func(files []pivnet.DownloadedFile) []string {
+		var names []string
+		for _, f := range files {
+			names = append(names, filepath.Base(f.Path))
+		}
+		return names
+	}
+
+	It("downloads every product file of the release and its file groups", func() {
+		files, err := client.ProductFiles.DownloadRelease(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(downloadedNames(files)).To(Equal([]string{
+			"tile.pivotal",
+			"notes.pdf",
+			"cli-windows.zip",
+			"stemcell.tgz",
+		}))
+
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "stemcell.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("contents of 4"))
+	})
+
+	Context("when globs are provided", func() {
+		BeforeEach(func() {
+			config.Globs = []string{"*.pivotal", "*.tgz"}
+		})
+
+		It("downloads only matching product files", func() {
+			files, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(downloadedNames(files)).To(Equal([]string{"tile.pivotal", "stemcell.tgz"}))
+
+			_, err = os.Stat(filepath.Join(dir, "notes.pdf"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		Context("when a glob matches nothing", func() {
+			BeforeEach(func() {
+				config.Globs = []string{"*.pivotal", "*.exe"}
+			})
+
+			It("returns an ErrNoGlobMatch without downloading", func() {
+				_, err := client.ProductFiles.DownloadRelease(config)
+				Expect(err).To(MatchError(pivnet.ErrNoGlobMatch{Glob: "*.exe"}))
+
+				entries, err := ioutil.ReadDir(dir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(BeEmpty())
+			})
+		})
+
+		Context("when a glob is malformed", func() {
+			BeforeEach(func() {
+				config.Globs = []string{"["}
+			})
+
+			It("returns an error", func() {
+				_, err := client.ProductFiles.DownloadRelease(config)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Context("when file types are provided", func() {
+		BeforeEach(func() {
+			config.FileTypes = []string{pivnet.FileTypeDocumentation}
+		})
+
+		It("downloads only product files of those types", func() {
+			files, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(downloadedNames(files)).To(Equal([]string{"notes.pdf"}))
+		})
+
+		Context("when they differ in case", func() {
+			BeforeEach(func() {
+				config.FileTypes = []string{strings.ToUpper(pivnet.FileTypeDocumentation)}
+			})
+
+			It("still matches them", func() {
+				files, err := client.ProductFiles.DownloadRelease(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(downloadedNames(files)).To(Equal([]string{"notes.pdf"}))
+			})
+		})
+	})
+
+	Context("when platforms are provided", func() {
+		BeforeEach(func() {
+			config.Platforms = []string{"Windows"}
+			config.Concurrency = 1
+		})
+
+		It("downloads only product files for those platforms", func() {
+			files, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(downloadedNames(files)).To(Equal([]string{"cli-windows.zip"}))
+		})
+
+		Context("when they differ in case", func() {
+			BeforeEach(func() {
+				config.Platforms = []string{"windows"}
+			})
+
+			It("still matches them", func() {
+				files, err := client.ProductFiles.DownloadRelease(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(downloadedNames(files)).To(Equal([]string{"cli-windows.zip"}))
+			})
+		})
+	})
+
+	Context("when two product files have the same file name", func() {
+		BeforeEach(func() {
+			productFiles = append(productFiles, productFile(5, "other/tile.pivotal", pivnet.FileTypeSoftware))
+		})
+
+		It("returns an error", func() {
+			_, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("tile.pivotal"))
+		})
+	})
+
+	Context("when a product file's AWS object key has no file name", func() {
+		BeforeEach(func() {
+			productFiles = append(productFiles, productFile(5, "product-files/..", pivnet.FileTypeSoftware))
+		})
+
+		It("returns an error naming the product file", func() {
+			_, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).To(MatchError(ContainSubstring("product file 5 has no usable file name")))
+		})
+	})
+
+	Context("when a download fails", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("POST",
+				fmt.Sprintf("%s/products/%s/releases/%d/product_files/2/download", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWith(http.StatusTeapot, `{"message":"download failed"}`),
+			)
+		})
+
+		It("returns the error and removes the partial file", func() {
+			_, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("download failed"))
+
+			_, err = os.Stat(filepath.Join(dir, "notes.pdf"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Context("when listing the product files fails", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+			)
+		})
+
+		It("returns the error", func() {
+			_, err := client.ProductFiles.DownloadRelease(config)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("foo message"))
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package pivnet
+
+import (
+	"time"
+)
+
+// LifecyclePhase is where a release is in its lifecycle on a given day.
+type LifecyclePhase string
+
+const (
+	// LifecyclePhaseUnreleased is before the release date.
+	LifecyclePhaseUnreleased LifecyclePhase = "unreleased"
+
+	// LifecyclePhaseSupported is up to and including the end of support date.
+	LifecyclePhaseSupported LifecyclePhase = "supported"
+
+	// LifecyclePhaseTechnicalGuidance is after the end of support date up to
+	// and including the end of guidance date.
+	LifecyclePhaseTechnicalGuidance LifecyclePhase = "technical_guidance"
+
+	// LifecyclePhaseUnsupported is after support and guidance have ended but
+	// the release can still be downloaded.
+	LifecyclePhaseUnsupported LifecyclePhase = "unsupported"
+
+	// LifecyclePhaseUnavailable is after the end of availability date.
+	LifecyclePhaseUnavailable LifecyclePhase = "unavailable"
+)
+
+// The date accessors parse the date strings Pivnet returns, which are plain
+// dates or RFC 3339 timestamps. They return false if the date is unset or
+// cannot be parsed.
+
+func (r Release) ReleaseDateTime() (time.Time, bool) {
+	return parseDate(r.ReleaseDate)
+}
+
+func (r Release) EndOfSupportTime() (time.Time, bool) {
+	return parseDate(r.EndOfSupportDate)
+}
+
+func (r Release) EndOfGuidanceTime() (time.Time, bool) {
+	return parseDate(r.EndOfGuidanceDate)
+}
+
+func (r Release) EndOfAvailabilityTime() (time.Time, bool) {
+	return parseDate(r.EndOfAvailabilityDate)
+}
+
+func (r Release) UpdatedAtTime() (time.Time, bool) {
+	return parseDate(r.UpdatedAt)
+}
+
+// LifecyclePhase returns the release's phase on the day of t. Lifecycle
+// dates are whole days in UTC and each phase includes its last day. Unset
+// dates are treated as never reached.
+func (r Release) LifecyclePhase(t time.Time) LifecyclePhase {
+	day := utcDay(t)
+
+	reached := func(date func() (time.Time, bool)) bool {
+		d, ok := date()
+		return ok && day.After(utcDay(d))
+	}
+
+	if d, ok := r.ReleaseDateTime(); ok && day.Before(utcDay(d)) {
+		return LifecyclePhaseUnreleased
+	}
+
+	switch {
+	case reached(r.EndOfAvailabilityTime):
+		return LifecyclePhaseUnavailable
+	case reached(r.EndOfGuidanceTime):
+		return LifecyclePhaseUnsupported
+	case reached(r.EndOfSupportTime):
+		if _, ok := r.EndOfGuidanceTime(); ok {
+			return LifecyclePhaseTechnicalGuidance
+		}
+		return LifecyclePhaseUnsupported
+	}
+
+	return LifecyclePhaseSupported
+}
+
+// IsSupported reports whether the release is supported on the day of t.
+func (r Release) IsSupported(t time.Time) bool {
+	return r.LifecyclePhase(t) == LifecyclePhaseSupported
+}
+
+// DaysUntilEndOfSupport returns the number of days from the day of t to the
+// end of support date, which is negative once support has ended. It returns
+// false if the release has no end of support date.
+func (r Release) DaysUntilEndOfSupport(t time.Time) (int, bool) {
+	return daysUntil(t, r.EndOfSupportTime)
+}
+
+// DaysUntilEndOfAvailability is DaysUntilEndOfSupport for the end of
+// availability date.
+func (r Release) DaysUntilEndOfAvailability(t time.Time) (int, bool) {
+	return daysUntil(t, r.EndOfAvailabilityTime)
+}
+
+func daysUntil(t time.Time, date func() (time.Time, bool)) (int, bool) {
+	d, ok := date()
+	if !ok {
+		return 0, false
+	}
+
+	return int(utcDay(d).Sub(utcDay(t)).Hours() / 24), true
+}
+
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,113 @@
+package pivnet_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/go-pivnet"
+)
+
+var _ = Describe("Release lifecycle", func() {
+	var release pivnet.Release
+
+	day := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02", s)
+		Expect(err).NotTo(HaveOccurred())
+		return t.Add(15 * time.Hour)
+	}
+
+	BeforeEach(func() {
+		release = pivnet.Release{
+			ReleaseDate:           "2016-01-01",
+			EndOfSupportDate:      "2017-01-01",
+			EndOfGuidanceDate:     "2017-06-01",
+			EndOfAvailabilityDate: "2018-01-01",
+			UpdatedAt:             "2016-02-03T04:05:06Z",
+		}
+	})
+
+	Describe("date accessors", func() {
+		It("parses plain dates and timestamps", func() {
+			releaseDate, ok := release.ReleaseDateTime()
+			Expect(ok).To(BeTrue())
+			Expect(releaseDate).To(Equal(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+			updatedAt, ok := release.UpdatedAtTime()
+			Expect(ok).To(BeTrue())
+			Expect(updatedAt).To(Equal(time.Date(2016, 2, 3, 4, 5, 6, 0, time.UTC)))
+		})
+
+		It("returns false for unset or malformed dates", func() {
+			release.EndOfSupportDate = ""
+			release.EndOfGuidanceDate = "June 2017"
+
+			_, ok := release.EndOfSupportTime()
+			Expect(ok).To(BeFalse())
+
+			_, ok = release.EndOfGuidanceTime()
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	DescribeTable("LifecyclePhase",
+		func(date string, expected pivnet.LifecyclePhase) {
+			Expect(release.LifecyclePhase(day(date))).To(Equal(expected))
+		},
+		Entry("before release", "2015-12-31", pivnet.LifecyclePhaseUnreleased),
+		Entry("on the release date", "2016-01-01", pivnet.LifecyclePhaseSupported),
+		Entry("on the last day of support", "2017-01-01", pivnet.LifecyclePhaseSupported),
+		Entry("after support", "2017-01-02", pivnet.LifecyclePhaseTechnicalGuidance),
+		Entry("after guidance", "2017-06-02", pivnet.LifecyclePhaseUnsupported),
+		Entry("after availability", "2018-01-02", pivnet.LifecyclePhaseUnavailable),
+	)
+
+	Context("when the release has no guidance date", func() {
+		BeforeEach(func() {
+			release.EndOfGuidanceDate = ""
+		})
+
+		It("is unsupported once support ends", func() {
+			Expect(release.LifecyclePhase(day("2017-01-02"))).To(Equal(pivnet.LifecyclePhaseUnsupported))
+		})
+	})
+
+	Context("when the release has no lifecycle dates", func() {
+		It("is supported", func() {
+			Expect(pivnet.Release{}.IsSupported(day("2030-01-01"))).To(BeTrue())
+		})
+	})
+
+	Describe("IsSupported", func() {
+		It("is true only while supported", func() {
+			Expect(release.IsSupported(day("2016-06-01"))).To(BeTrue())
+			Expect(release.IsSupported(day("2017-02-01"))).To(BeFalse())
+		})
+	})
+
+	Describe("DaysUntilEndOfSupport", func() {
+		It("counts whole days, going negative after support ends", func() {
+			days, ok := release.DaysUntilEndOfSupport(day("2016-12-22"))
+			Expect(ok).To(BeTrue())
+			Expect(days).To(Equal(10))
+
+			days, ok = release.DaysUntilEndOfSupport(day("2017-01-03"))
+			Expect(ok).To(BeTrue())
+			Expect(days).To(Equal(-2))
+		})
+
+		It("returns false without an end of support date", func() {
+			_, ok := pivnet.Release{}.DaysUntilEndOfSupport(day("2017-01-01"))
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("DaysUntilEndOfAvailability", func() {
+		It("counts days to the end of availability", func() {
+			days, ok := release.DaysUntilEndOfAvailability(day("2017-12-31"))
+			Expect(ok).To(BeTrue())
+			Expect(days).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,130 @@
+package pivnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ReleasePatch is a partial update of a release. Only the fields that are
+// set are sent; a string field set to "" is sent as null, clearing it.
+type ReleasePatch struct {
+	Availability          *ReleaseAvailability
+	EULASlug              *string
+	OSSCompliant          *string
+	ReleaseDate           *string
+	ReleaseType           *ReleaseType
+	Version               *string
+	Description           *string
+	ReleaseNotesURL       *string
+	Controlled            *bool
+	ECCN                  *string
+	LicenseException      *string
+	EndOfSupportDate      *string
+	EndOfGuidanceDate     *string
+	EndOfAvailabilityDate *string
+}
+
+// String returns a pointer to v, for setting fields of a patch.
+func String(v string) *string {
+	return &v
+}
+
+// Bool returns a pointer to v, for setting fields of a patch.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Patch updates only the fields the patch sets. Unlike Update, it does not
+// confirm OSS compliance unless the patch sets OSSCompliant.
+func (r ReleasesService) Patch(productSlug string, releaseID int, patch ReleasePatch) (Release, error) {
+	fields := patch.fields()
+	if len(fields) == 0 {
+		return Release{}, fmt.Errorf("release patch sets no fields")
+	}
+
+	url := fmt.Sprintf(
+		"/products/%s/releases/%d",
+		productSlug,
+		releaseID,
+	)
+
+	body, err := json.Marshal(map[string]interface{}{"release": fields})
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling
+		// a known-good body
+		return Release{}, err
+	}
+
+	var response CreateReleaseResponse
+	resp, err := r.client.MakeRequest(
+		"PATCH",
+		url,
+		http.StatusOK,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return Release{}, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return Release{}, err
+	}
+
+	return response.Release, nil
+}
+
+// fields returns the JSON fields of the release the patch sets.
+func (p ReleasePatch) fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	set := func(name string, value *string) {
+		if value == nil {
+			return
+		}
+
+		if *value == "" {
+			fields[name] = nil
+		} else {
+			fields[name] = *value
+		}
+	}
+
+	if p.Availability != nil {
+		availability := string(*p.Availability)
+		set("availability", &availability)
+	}
+
+	if p.EULASlug != nil {
+		if *p.EULASlug == "" {
+			fields["eula"] = nil
+		} else {
+			fields["eula"] = EULA{Slug: *p.EULASlug}
+		}
+	}
+
+	if p.ReleaseType != nil {
+		releaseType := string(*p.ReleaseType)
+		set("release_type", &releaseType)
+	}
+
+	set("oss_compliant", p.OSSCompliant)
+	set("release_date", p.ReleaseDate)
+	set("version", p.Version)
+	set("description", p.Description)
+	set("release_notes_url", p.ReleaseNotesURL)
+	set("eccn", p.ECCN)
+	set("license_exception", p.LicenseException)
+	set("end_of_support_date", p.EndOfSupportDate)
+	set("end_of_guidance_date", p.EndOfGuidanceDate)
+	set("end_of_availability_date", p.EndOfAvailabilityDate)
+
+	if p.Controlled != nil {
+		fields["controlled"] = *p.Controlled
+	}
+
+	return fields
+}
@@ -0,0 +1,115 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release patch", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		patchURL string
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		patchURL = fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, 42)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Patch", func() {
+		It("sends only the fields that are set", func() {
+			availability := pivnet.AvailabilityAllUsers
+
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PATCH", patchURL),
+					ghttp.VerifyJSON(`{"release":{
+						"availability": "All Users",
+						"eula": {"slug": "some-eula"},
+						"description": "some description",
+						"controlled": false
+					}}`),
+					ghttp.RespondWith(http.StatusOK, `{"release": {"id": 42, "availability": "All Users"}}`),
+				),
+			)
+
+			release, err := client.Releases.Patch(productSlug, 42, pivnet.ReleasePatch{
+				Availability: &availability,
+				EULASlug:     pivnet.String("some-eula"),
+				Description:  pivnet.String("some description"),
+				Controlled:   pivnet.Bool(false),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.Availability).To(Equal(pivnet.AvailabilityAllUsers))
+		})
+
+		It("clears fields set to empty strings", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PATCH", patchURL),
+					ghttp.VerifyJSON(`{"release":{"release_notes_url": null, "end_of_support_date": null}}`),
+					ghttp.RespondWith(http.StatusOK, `{"release": {"id": 42}}`),
+				),
+			)
+
+			_, err := client.Releases.Patch(productSlug, 42, pivnet.ReleasePatch{
+				ReleaseNotesURL:  pivnet.String(""),
+				EndOfSupportDate: pivnet.String(""),
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the patch sets no fields", func() {
+			It("returns an error without calling Pivnet", func() {
+				_, err := client.Releases.Patch(productSlug, 42, pivnet.ReleasePatch{})
+				Expect(err).To(MatchError("release patch sets no fields"))
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when the server responds with a non-200 status code", func() {
+			It("returns the error", func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PATCH", patchURL),
+						ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+					),
+				)
+
+				_, err := client.Releases.Patch(productSlug, 42, pivnet.ReleasePatch{Version: pivnet.String("1.0.1")})
+				Expect(err.Error()).To(ContainSubstring("foo message"))
+			})
+		})
+	})
+})
@@ -0,0 +1,318 @@
+package pivnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+// ReadinessCheck names something that must hold before a release is made
+// available beyond admins.
+type ReadinessCheck string
+
+const (
+	CheckProductFilesReady  ReadinessCheck = "product_files_ready"
+	CheckEULASet            ReadinessCheck = "eula_set"
+	CheckReleaseNotesURL    ReadinessCheck = "release_notes_url"
+	CheckUpgradePaths       ReadinessCheck = "upgrade_paths"
+	CheckDependencies       ReadinessCheck = "dependencies"
+	CheckFileGroupsNotEmpty ReadinessCheck = "file_groups_not_empty"
+)
+
+// AllReadinessChecks are the checks run when none are configured.
+var AllReadinessChecks = []ReadinessCheck{
+	CheckProductFilesReady,
+	CheckEULASet,
+	CheckReleaseNotesURL,
+	CheckUpgradePaths,
+	CheckDependencies,
+	CheckFileGroupsNotEmpty,
+}
+
+type CheckResult struct {
+	Check  ReadinessCheck `json:"check" yaml:"check"`
+	Passed bool           `json:"passed" yaml:"passed"`
+
+	// Message explains a failure.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+type ReadinessReport struct {
+	ProductSlug string        `json:"product_slug" yaml:"product_slug"`
+	Version     string        `json:"version" yaml:"version"`
+	Results     []CheckResult `json:"results" yaml:"results"`
+
+	// Release is the release as checked, or as updated if it was promoted.
+	Release  Release `json:"release" yaml:"release"`
+	Promoted bool    `json:"promoted" yaml:"promoted"`
+}
+
+type ReadinessConfig struct {
+	ProductSlug string
+	ReleaseID   int
+
+	// Checks defaults to AllReadinessChecks.
+	Checks []ReadinessCheck
+}
+
+type PromoteConfig struct {
+	ProductSlug string
+	ReleaseID   int
+
+	// Availability defaults to All Users. UserGroupIDs are attached before
+	// promotion, as for SetAvailability.
+	Availability ReleaseAvailability
+	UserGroupIDs []int
+
+	// Checks defaults to AllReadinessChecks.
+	Checks []ReadinessCheck
+}
+
+type ErrNotReady struct {
+	ProductSlug string        `json:"product_slug" yaml:"product_slug"`
+	Version     string        `json:"version" yaml:"version"`
+	Failed      []CheckResult `json:"failed" yaml:"failed"`
+}
+
+func (e ErrNotReady) Error() string {
+	var failed []string
+	for _, f := range e.Failed {
+		failed = append(failed, fmt.Sprintf("%s (%s)", f.Check, f.Message))
+	}
+
+	return fmt.Sprintf(
+		"release %s of %s is not ready: %s",
+		e.Version,
+		e.ProductSlug,
+		strings.Join(failed, ", "),
+	)
+}
+
+// Ready reports whether every check passed.
+func (r ReadinessReport) Ready() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed returns the results of the checks that failed.
+func (r ReadinessReport) Failed() []CheckResult {
+	var failed []CheckResult
+	for _, result := range r.Results {
+		if !result.Passed {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// CheckReadiness runs readiness checks against a release without changing it.
+func (r ReleasesService) CheckReadiness(config ReadinessConfig) (ReadinessReport, error) {
+	checks := config.Checks
+	if len(checks) == 0 {
+		checks = AllReadinessChecks
+	}
+
+	contents, err := r.releaseContents(config.ProductSlug, config.ReleaseID)
+	if err != nil {
+		return ReadinessReport{}, err
+	}
+
+	report := ReadinessReport{
+		ProductSlug: config.ProductSlug,
+		Version:     contents.release.Version,
+		Release:     contents.release,
+	}
+
+	for _, check := range checks {
+		result, err := r.runReadinessCheck(config.ProductSlug, check, contents)
+		if err != nil {
+			return ReadinessReport{}, err
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	return report, nil
+}
+
+// Promote runs readiness checks against a release and, only if they all
+// pass, changes its availability. If any fail, the report is returned with
+// ErrNotReady and the release is left unchanged.
+func (r ReleasesService) Promote(config PromoteConfig) (ReadinessReport, error) {
+	report, err := r.CheckReadiness(ReadinessConfig{
+		ProductSlug: config.ProductSlug,
+		ReleaseID:   config.ReleaseID,
+		Checks:      config.Checks,
+	})
+	if err != nil {
+		return ReadinessReport{}, err
+	}
+
+	if !report.Ready() {
+		return report, ErrNotReady{
+			ProductSlug: report.ProductSlug,
+			Version:     report.Version,
+			Failed:      report.Failed(),
+		}
+	}
+
+	availability := config.Availability
+	if availability == "" {
+		availability = AvailabilityAllUsers
+	}
+
+	r.l.Info("Promoting release", logger.Data{
+		"version":      report.Version,
+		"availability": availability,
+	})
+
+	report.Release, err = r.SetAvailability(SetAvailabilityConfig{
+		ProductSlug:  config.ProductSlug,
+		ReleaseID:    config.ReleaseID,
+		Availability: availability,
+		UserGroupIDs: config.UserGroupIDs,
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.Promoted = true
+
+	return report, nil
+}
+
+func (r ReleasesService) runReadinessCheck(
+	productSlug string,
+	check ReadinessCheck,
+	contents releaseContents,
+) (CheckResult, error) {
+	result := CheckResult{Check: check}
+
+	fail := func(format string, a ...interface{}) (CheckResult, error) {
+		result.Message = fmt.Sprintf(format, a...)
+		return result, nil
+	}
+
+	switch check {
+	case CheckProductFilesReady:
+		productFiles, err := ProductFilesService{client: r.client}.releaseProductFiles(productSlug, contents.release.ID)
+		if err != nil {
+			return CheckResult{}, err
+		}
+
+		if len(productFiles) == 0 {
+			return fail("no product files are attached")
+		}
+
+		notReady, err := r.productFilesNotReady(productSlug, productFiles)
+		if err != nil {
+			return CheckResult{}, err
+		}
+
+		if len(notReady) > 0 {
+			return fail("not ready to serve: %s", strings.Join(notReady, ", "))
+		}
+
+	case CheckEULASet:
+		if contents.release.EULA == nil || contents.release.EULA.Slug == "" {
+			return fail("no EULA is set")
+		}
+
+	case CheckReleaseNotesURL:
+		if contents.release.ReleaseNotesURL == "" {
+			return fail("no release notes URL is set")
+		}
+
+	case CheckUpgradePaths:
+		if len(contents.upgradePaths) == 0 {
+			return fail("no upgrade paths are defined")
+		}
+
+	case CheckDependencies:
+		if len(contents.dependencies) == 0 {
+			return fail("no dependencies are defined")
+		}
+
+	case CheckFileGroupsNotEmpty:
+		var empty []string
+		for _, g := range contents.fileGroups {
+			if len(g.ProductFiles) == 0 {
+				empty = append(empty, g.Name)
+			}
+		}
+
+		if len(empty) > 0 {
+			return fail("empty file groups: %s", strings.Join(empty, ", "))
+		}
+
+	default:
+		return CheckResult{}, fmt.Errorf("unknown readiness check: %s", check)
+	}
+
+	result.Passed = true
+
+	return result, nil
+}
+
+// productFilesNotReady returns the names of the product files that are not
+// ready to serve. A completed transfer is not enough, unlike for IsReady.
+// Files the release listing does not show as ready are fetched
+// individually, as the listing may omit their status.
+func (r ReleasesService) productFilesNotReady(productSlug string, productFiles []ProductFile) ([]string, error) {
+	var notReady []string
+
+	for _, pf := range productFiles {
+		if pf.ReadyToServe {
+			continue
+		}
+
+		current, err := ProductFilesService{client: r.client}.Get(productSlug, pf.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !current.ReadyToServe {
+			notReady = append(notReady, current.Name)
+		}
+	}
+
+	return notReady, nil
+}
+
+// Write writes the report as text or JSON.
+func (r ReadinessReport) Write(w io.Writer, format OutputFormat) error {
+	switch format {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case OutputFormatText:
+		_, err := io.WriteString(w, r.text())
+		return err
+	}
+
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+func (r ReadinessReport) text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s\n", r.ProductSlug, r.Version)
+
+	for _, result := range r.Results {
+		if result.Passed {
+			fmt.Fprintf(&b, "  PASS %s\n", result.Check)
+		} else {
+			fmt.Fprintf(&b, "  FAIL %s: %s\n", result.Check, result.Message)
+		}
+	}
+
+	if r.Promoted {
+		fmt.Fprintf(&b, "Promoted to %s\n", r.Release.Availability)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,241 @@
+package pivnet_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release readiness", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		fake    *fakePivnet
+		release pivnet.Release
+		tile    pivnet.ProductFile
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		fake = newFakePivnet()
+		fake.routeFrom(server)
+
+		previous := fake.addRelease(productSlug, pivnet.Release{Version: "1.0.0"})
+		stemcell := fake.addRelease("stemcells", pivnet.Release{Version: "3000.1"})
+		release = fake.addRelease(productSlug, pivnet.Release{
+			Version:         "1.1.0",
+			Availability:    pivnet.AvailabilityAdminsOnly,
+			EULA:            &pivnet.EULA{Slug: "some-eula"},
+			ReleaseNotesURL: "https://example.com/notes",
+		})
+
+		tile = fake.addProductFile(productSlug, pivnet.ProductFile{Name: "Tile", ReadyToServe: true})
+		fake.fileGroups[productSlug] = []pivnet.FileGroup{{ID: 7, Name: "Stemcells", ProductFiles: []pivnet.ProductFile{tile}}}
+
+		fake.attach(release.ID, "product_file", tile.ID)
+		fake.attach(release.ID, "file_group", 7)
+		fake.attach(release.ID, "upgrade_path", previous.ID)
+		fake.attach(release.ID, "dependency", stemcell.ID)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("CheckReadiness", func() {
+		It("passes every check for a complete release", func() {
+			report, err := client.Releases.CheckReadiness(pivnet.ReadinessConfig{
+				ProductSlug: productSlug,
+				ReleaseID:   release.ID,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Version).To(Equal("1.1.0"))
+			Expect(report.Ready()).To(BeTrue())
+			Expect(report.Results).To(HaveLen(len(pivnet.AllReadinessChecks)))
+		})
+
+		Context("when the release is incomplete", func() {
+			BeforeEach(func() {
+				fake.addProductFile(productSlug, pivnet.ProductFile{Name: "Docs", FileTransferStatus: "in_progress"})
+				fake.attach(release.ID, "product_file", fake.productFiles[productSlug][1].ID)
+
+				fake.fileGroups[productSlug] = append(fake.fileGroups[productSlug], pivnet.FileGroup{ID: 9, Name: "Empty"})
+				fake.attach(release.ID, "file_group", 9)
+
+				r := fake.release(productSlug, release.ID)
+				r.EULA = nil
+				r.ReleaseNotesURL = ""
+
+				fake.attached[release.ID]["upgrade_path"] = nil
+				fake.attached[release.ID]["dependency"] = nil
+			})
+
+			It("reports every failing check", func() {
+				report, err := client.Releases.CheckReadiness(pivnet.ReadinessConfig{
+					ProductSlug: productSlug,
+					ReleaseID:   release.ID,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(report.Ready()).To(BeFalse())
+				Expect(report.Failed()).To(Equal([]pivnet.CheckResult{
+					{Check: pivnet.CheckProductFilesReady, Message: "not ready to serve: Docs"},
+					{Check: pivnet.CheckEULASet, Message: "no EULA is set"},
+					{Check: pivnet.CheckReleaseNotesURL, Message: "no release notes URL is set"},
+					{Check: pivnet.CheckUpgradePaths, Message: "no upgrade paths are defined"},
+					{Check: pivnet.CheckDependencies, Message: "no dependencies are defined"},
+					{Check: pivnet.CheckFileGroupsNotEmpty, Message: "empty file groups: Empty"},
+				}))
+			})
+
+			It("runs only the configured checks", func() {
+				report, err := client.Releases.CheckReadiness(pivnet.ReadinessConfig{
+					ProductSlug: productSlug,
+					ReleaseID:   release.ID,
+					Checks:      []pivnet.ReadinessCheck{pivnet.CheckEULASet},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Results).To(Equal([]pivnet.CheckResult{
+					{Check: pivnet.CheckEULASet, Message: "no EULA is set"},
+				}))
+			})
+		})
+
+		Context("when a product file has been transferred but is not ready to serve", func() {
+			BeforeEach(func() {
+				transferred := fake.addProductFile(productSlug, pivnet.ProductFile{
+					Name:               "Transferred",
+					FileTransferStatus: "complete",
+				})
+				fake.attach(release.ID, "product_file", transferred.ID)
+			})
+
+			It("reports it as not ready", func() {
+				report, err := client.Releases.CheckReadiness(pivnet.ReadinessConfig{
+					ProductSlug: productSlug,
+					ReleaseID:   release.ID,
+					Checks:      []pivnet.ReadinessCheck{pivnet.CheckProductFilesReady},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Results).To(Equal([]pivnet.CheckResult{
+					{Check: pivnet.CheckProductFilesReady, Message: "not ready to serve: Transferred"},
+				}))
+			})
+		})
+
+		Context("when the release ships files only through file groups", func() {
+			BeforeEach(func() {
+				docs := fake.addProductFile(productSlug, pivnet.ProductFile{Name: "Docs", FileTransferStatus: "in_progress"})
+				fake.fileGroups[productSlug][0].ProductFiles = append(fake.fileGroups[productSlug][0].ProductFiles, docs)
+
+				fake.attached[release.ID]["product_file"] = nil
+			})
+
+			It("checks the files in the file groups", func() {
+				report, err := client.Releases.CheckReadiness(pivnet.ReadinessConfig{
+					ProductSlug: productSlug,
+					ReleaseID:   release.ID,
+					Checks:      []pivnet.ReadinessCheck{pivnet.CheckProductFilesReady},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Results).To(Equal([]pivnet.CheckResult{
+					{Check: pivnet.CheckProductFilesReady, Message: "not ready to serve: Docs"},
+				}))
+			})
+		})
+
+		Context("when a check is unknown", func() {
+			It("returns an error", func() {
+				_, err := client.Releases.CheckReadiness(pivnet.ReadinessConfig{
+					ProductSlug: productSlug,
+					ReleaseID:   release.ID,
+					Checks:      []pivnet.ReadinessCheck{"signed"},
+				})
+				Expect(err).To(MatchError("unknown readiness check: signed"))
+			})
+		})
+	})
+
+	Describe("Promote", func() {
+		It("makes a ready release available to all users", func() {
+			report, err := client.Releases.Promote(pivnet.PromoteConfig{
+				ProductSlug: productSlug,
+				ReleaseID:   release.ID,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Promoted).To(BeTrue())
+			Expect(report.Release.Availability).To(Equal(pivnet.AvailabilityAllUsers))
+			Expect(fake.release(productSlug, release.ID).Availability).To(Equal(pivnet.AvailabilityAllUsers))
+
+			var b bytes.Buffer
+			Expect(report.Write(&b, pivnet.OutputFormatText)).To(Succeed())
+			Expect(b.String()).To(ContainSubstring("  PASS eula_set\n"))
+			Expect(b.String()).To(ContainSubstring("Promoted to All Users\n"))
+		})
+
+		Context("when a check fails", func() {
+			BeforeEach(func() {
+				fake.release(productSlug, release.ID).ReleaseNotesURL = ""
+			})
+
+			It("returns ErrNotReady and leaves the release alone", func() {
+				report, err := client.Releases.Promote(pivnet.PromoteConfig{
+					ProductSlug: productSlug,
+					ReleaseID:   release.ID,
+				})
+				Expect(err).To(MatchError(pivnet.ErrNotReady{
+					ProductSlug: productSlug,
+					Version:     "1.1.0",
+					Failed: []pivnet.CheckResult{
+						{Check: pivnet.CheckReleaseNotesURL, Message: "no release notes URL is set"},
+					},
+				}))
+
+				Expect(report.Promoted).To(BeFalse())
+				Expect(fake.writeCount()).To(BeZero())
+
+				var b bytes.Buffer
+				Expect(report.Write(&b, pivnet.OutputFormatText)).To(Succeed())
+				Expect(b.String()).To(ContainSubstring("  FAIL release_notes_url: no release notes URL is set\n"))
+			})
+
+			Context("when that check is not configured", func() {
+				It("promotes the release", func() {
+					report, err := client.Releases.Promote(pivnet.PromoteConfig{
+						ProductSlug: productSlug,
+						ReleaseID:   release.ID,
+						Checks:      []pivnet.ReadinessCheck{pivnet.CheckProductFilesReady},
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(report.Promoted).To(BeTrue())
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,841 @@
+package pivnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	PlanActionCreate = "create"
+	PlanActionUpdate = "update"
+	PlanActionAttach = "attach"
+	PlanActionDetach = "detach"
+)
+
+const (
+	PlanKindRelease     = "release"
+	PlanKindProductFile = "product_file"
+	PlanKindFileGroup   = "file_group"
+	PlanKindUserGroup   = "user_group"
+	PlanKindDependency  = "dependency"
+	PlanKindUpgradePath = "upgrade_path"
+)
+
+// ReleaseSpec declares a release and everything attached to it. Fields left
+// empty are not managed. The lists of attachments are not managed if they
+// are nil, i.e. missing from the spec; otherwise they are reconciled
+// exactly, so anything attached to the release but not listed is detached,
+// and an empty list detaches everything.
+type ReleaseSpec struct {
+	ProductSlug  string             `json:"product_slug" yaml:"product_slug"`
+	Release      ReleaseSpecFields  `json:"release" yaml:"release"`
+	ProductFiles *[]ProductFileSpec `json:"product_files,omitempty" yaml:"product_files,omitempty"`
+
+	// FileGroups and UserGroups are names of existing groups.
+	FileGroups *[]string `json:"file_groups,omitempty" yaml:"file_groups,omitempty"`
+	UserGroups *[]string `json:"user_groups,omitempty" yaml:"user_groups,omitempty"`
+
+	Dependencies *[]DependencySpec `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+
+	// UpgradePaths are versions of releases of the same product that can be
+	// upgraded from.
+	UpgradePaths *[]string `json:"upgrade_paths,omitempty" yaml:"upgrade_paths,omitempty"`
+}
+
+type ReleaseSpecFields struct {
+	Version               string `json:"version" yaml:"version"`
+	ReleaseType           string `json:"release_type,omitempty" yaml:"release_type,omitempty"`
+	ReleaseDate           string `json:"release_date,omitempty" yaml:"release_date,omitempty"`
+	EULASlug              string `json:"eula_slug,omitempty" yaml:"eula_slug,omitempty"`
+	Description           string `json:"description,omitempty" yaml:"description,omitempty"`
+	ReleaseNotesURL       string `json:"release_notes_url,omitempty" yaml:"release_notes_url,omitempty"`
+	Controlled            *bool  `json:"controlled,omitempty" yaml:"controlled,omitempty"`
+	ECCN                  string `json:"eccn,omitempty" yaml:"eccn,omitempty"`
+	LicenseException      string `json:"license_exception,omitempty" yaml:"license_exception,omitempty"`
+	EndOfSupportDate      string `json:"end_of_support_date,omitempty" yaml:"end_of_support_date,omitempty"`
+	EndOfGuidanceDate     string `json:"end_of_guidance_date,omitempty" yaml:"end_of_guidance_date,omitempty"`
+	EndOfAvailabilityDate string `json:"end_of_availability_date,omitempty" yaml:"end_of_availability_date,omitempty"`
+}
+
+// ProductFileSpec declares a product file, identified by its AWS object key.
+// A product file with the key is created if the product has none.
+type ProductFileSpec struct {
+	AWSObjectKey       string   `json:"aws_object_key" yaml:"aws_object_key"`
+	Name               string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Description        string   `json:"description,omitempty" yaml:"description,omitempty"`
+	DocsURL            string   `json:"docs_url,omitempty" yaml:"docs_url,omitempty"`
+	FileType           string   `json:"file_type,omitempty" yaml:"file_type,omitempty"`
+	FileVersion        string   `json:"file_version,omitempty" yaml:"file_version,omitempty"`
+	IncludedFiles      []string `json:"included_files,omitempty" yaml:"included_files,omitempty"`
+	MD5                string   `json:"md5,omitempty" yaml:"md5,omitempty"`
+	Platforms          []string `json:"platforms,omitempty" yaml:"platforms,omitempty"`
+	ReleasedAt         string   `json:"released_at,omitempty" yaml:"released_at,omitempty"`
+	SystemRequirements []string `json:"system_requirements,omitempty" yaml:"system_requirements,omitempty"`
+}
+
+// DependencySpec is a release of another product the release depends on.
+type DependencySpec struct {
+	ProductSlug string `json:"product_slug" yaml:"product_slug"`
+	Version     string `json:"version" yaml:"version"`
+}
+
+type ErrInvalidReleaseSpec struct {
+	Problems []string `json:"problems" yaml:"problems"`
+}
+
+func (e ErrInvalidReleaseSpec) Error() string {
+	return fmt.Sprintf("invalid release spec: %s", strings.Join(e.Problems, "; "))
+}
+
+// ParseReleaseSpec reads a release spec in YAML or JSON.
+func ParseReleaseSpec(r io.Reader) (ReleaseSpec, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return ReleaseSpec{}, err
+	}
+
+	var spec ReleaseSpec
+	err = yaml.Unmarshal(b, &spec)
+	if err != nil {
+		return ReleaseSpec{}, err
+	}
+
+	return spec, nil
+}
+
+// ReleasePlan is the steps that bring Pivnet in line with a release spec.
+type ReleasePlan struct {
+	ProductSlug string `json:"product_slug" yaml:"product_slug"`
+	Version     string `json:"version" yaml:"version"`
+
+	// ReleaseID is the ID of the existing release, or 0 if it is created.
+	ReleaseID int        `json:"release_id,omitempty" yaml:"release_id,omitempty"`
+	Steps     []PlanStep `json:"steps" yaml:"steps"`
+}
+
+type PlanStep struct {
+	Action  string        `json:"action" yaml:"action"`
+	Kind    string        `json:"kind" yaml:"kind"`
+	Name    string        `json:"name" yaml:"name"`
+	Changes []FieldChange `json:"changes,omitempty" yaml:"changes,omitempty"`
+
+	apply func(s *applyState) error
+}
+
+// applyState carries what earlier steps created to later ones.
+type applyState struct {
+	client         Client
+	release        Release
+	productFileIDs map[string]int
+}
+
+func (s *applyState) releases() ReleasesService {
+	return ReleasesService{client: s.client, l: s.client.logger}
+}
+
+func (s *applyState) productFiles() ProductFilesService {
+	return ProductFilesService{client: s.client}
+}
+
+// Empty reports whether Pivnet already matches the spec.
+func (p ReleasePlan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Write writes the plan as text or JSON.
+func (p ReleasePlan) Write(w io.Writer, format OutputFormat) error {
+	switch format {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(p)
+	case OutputFormatText:
+		_, err := io.WriteString(w, p.text())
+		return err
+	}
+
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+var planActionSymbols = map[string]string{
+	PlanActionCreate: "+",
+	PlanActionUpdate: "~",
+	PlanActionAttach: ">",
+	PlanActionDetach: "<",
+}
+
+func (p ReleasePlan) text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s\n", p.ProductSlug, p.Version)
+
+	if p.Empty() {
+		b.WriteString("No changes\n")
+		return b.String()
+	}
+
+	for _, step := range p.Steps {
+		fmt.Fprintf(&b, "  %s %s %s %s\n", planActionSymbols[step.Action], step.Action, step.Kind, step.Name)
+		for _, c := range step.Changes {
+			fmt.Fprintf(&b, "      %s: %q -> %q\n", c.Field, c.From, c.To)
+		}
+	}
+
+	return b.String()
+}
+
+// Plan compares a release spec with the current state of Pivnet and returns
+// the steps Apply would take. It makes no changes.
+func (r ReleasesService) Plan(spec ReleaseSpec) (ReleasePlan, error) {
+	err := spec.validate()
+	if err != nil {
+		return ReleasePlan{}, err
+	}
+
+	slug := spec.ProductSlug
+	plan := ReleasePlan{ProductSlug: slug, Version: spec.Release.Version}
+
+	releases, err := r.List(slug)
+	if err != nil {
+		return ReleasePlan{}, err
+	}
+
+	releaseIDs := map[string]int{}
+	var current *Release
+	for i, release := range releases {
+		releaseIDs[release.Version] = release.ID
+		if release.Version == spec.Release.Version {
+			current = &releases[i]
+		}
+	}
+
+	var attached releaseContents
+	if current == nil {
+		plan.Steps = append(plan.Steps, createReleaseStep(spec))
+	} else {
+		plan.ReleaseID = current.ID
+
+		attached, err = r.releaseContents(slug, current.ID)
+		if err != nil {
+			return ReleasePlan{}, err
+		}
+
+		if step, ok := updateReleaseStep(spec, attached.release); ok {
+			plan.Steps = append(plan.Steps, step)
+		}
+	}
+
+	productFileSteps, err := r.planProductFiles(spec, attached.productFiles)
+	if err != nil {
+		return ReleasePlan{}, err
+	}
+	plan.Steps = append(plan.Steps, productFileSteps...)
+
+	fileGroupSteps, err := r.planFileGroups(spec, attached.fileGroups)
+	if err != nil {
+		return ReleasePlan{}, err
+	}
+	plan.Steps = append(plan.Steps, fileGroupSteps...)
+
+	userGroupSteps, err := r.planUserGroups(spec, attached.userGroups)
+	if err != nil {
+		return ReleasePlan{}, err
+	}
+	plan.Steps = append(plan.Steps, userGroupSteps...)
+
+	dependencySteps, err := r.planDependencies(spec, attached.dependencies)
+	if err != nil {
+		return ReleasePlan{}, err
+	}
+	plan.Steps = append(plan.Steps, dependencySteps...)
+
+	upgradePathSteps, err := r.planUpgradePaths(spec, releaseIDs, attached.upgradePaths)
+	if err != nil {
+		return ReleasePlan{}, err
+	}
+	plan.Steps = append(plan.Steps, upgradePathSteps...)
+
+	return plan, nil
+}
+
+// Apply takes the steps of a plan in order and returns the release. If a
+// step fails, planning again yields the steps that remain. Only plans
+// returned by Plan can be applied; a plan that was built or decoded by the
+// caller is rejected before anything is changed.
+func (r ReleasesService) Apply(plan ReleasePlan) (Release, error) {
+	for _, step := range plan.Steps {
+		if step.apply == nil {
+			return Release{}, fmt.Errorf(
+				"cannot apply %s %s %s: only steps returned by Plan can be applied",
+				step.Action,
+				step.Kind,
+				step.Name,
+			)
+		}
+	}
+
+	state := &applyState{client: r.client, productFileIDs: map[string]int{}}
+
+	if plan.ReleaseID != 0 {
+		var err error
+		state.release, err = r.Get(plan.ProductSlug, plan.ReleaseID)
+		if err != nil {
+			return Release{}, err
+		}
+	}
+
+	for _, step := range plan.Steps {
+		r.l.Info("Applying plan step", logger.Data{
+			"action": step.Action,
+			"kind":   step.Kind,
+			"name":   step.Name,
+		})
+
+		err := step.apply(state)
+		if err != nil {
+			return state.release, fmt.Errorf("could not %s %s %s: %s", step.Action, step.Kind, step.Name, err)
+		}
+	}
+
+	return state.release, nil
+}
+
+func (s ReleaseSpec) validate() error {
+	var problems []string
+
+	if s.ProductSlug == "" {
+		problems = append(problems, "product_slug must be set")
+	}
+
+	if s.Release.Version == "" {
+		problems = append(problems, "release.version must be set")
+	}
+
+	var productFiles []ProductFileSpec
+	if s.ProductFiles != nil {
+		productFiles = *s.ProductFiles
+	}
+
+	keys := map[string]bool{}
+	for i, pf := range productFiles {
+		if pf.AWSObjectKey == "" {
+			problems = append(problems, fmt.Sprintf("product_files[%d].aws_object_key must be set", i))
+		} else if keys[pf.AWSObjectKey] {
+			problems = append(problems, fmt.Sprintf("product_files[%d].aws_object_key %s is repeated", i, pf.AWSObjectKey))
+		}
+		keys[pf.AWSObjectKey] = true
+	}
+
+	var dependencies []DependencySpec
+	if s.Dependencies != nil {
+		dependencies = *s.Dependencies
+	}
+
+	for i, d := range dependencies {
+		if d.ProductSlug == "" || d.Version == "" {
+			problems = append(problems, fmt.Sprintf("dependencies[%d] must set product_slug and version", i))
+		}
+	}
+
+	if len(problems) > 0 {
+		return ErrInvalidReleaseSpec{Problems: problems}
+	}
+
+	return nil
+}
+
+func createReleaseStep(spec ReleaseSpec) PlanStep {
+	f := spec.Release
+
+	return PlanStep{
+		Action: PlanActionCreate,
+		Kind:   PlanKindRelease,
+		Name:   f.Version,
+		apply: func(s *applyState) error {
+			config := CreateReleaseConfig{
+				ProductSlug:           spec.ProductSlug,
+				Version:               f.Version,
+				ReleaseType:           f.ReleaseType,
+				ReleaseDate:           f.ReleaseDate,
+				EULASlug:              f.EULASlug,
+				Description:           f.Description,
+				ReleaseNotesURL:       f.ReleaseNotesURL,
+				ECCN:                  f.ECCN,
+				LicenseException:      f.LicenseException,
+				EndOfSupportDate:      f.EndOfSupportDate,
+				EndOfGuidanceDate:     f.EndOfGuidanceDate,
+				EndOfAvailabilityDate: f.EndOfAvailabilityDate,
+			}
+			if f.Controlled != nil {
+				config.Controlled = *f.Controlled
+			}
+
+			release, err := s.releases().Create(config)
+			s.release = release
+			return err
+		},
+	}
+}
+
+// updateReleaseStep returns a step patching the fields the spec sets that
+// differ from the current release.
+func updateReleaseStep(spec ReleaseSpec, current Release) (PlanStep, bool) {
+	desired := current
+	f := spec.Release
+
+	set := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+
+	releaseType := string(current.ReleaseType)
+	set(&releaseType, f.ReleaseType)
+	desired.ReleaseType = ReleaseType(releaseType)
+
+	set(&desired.ReleaseDate, f.ReleaseDate)
+	set(&desired.Description, f.Description)
+	set(&desired.ReleaseNotesURL, f.ReleaseNotesURL)
+	set(&desired.ECCN, f.ECCN)
+	set(&desired.LicenseException, f.LicenseException)
+	set(&desired.EndOfSupportDate, f.EndOfSupportDate)
+	set(&desired.EndOfGuidanceDate, f.EndOfGuidanceDate)
+	set(&desired.EndOfAvailabilityDate, f.EndOfAvailabilityDate)
+
+	if f.EULASlug != "" && (current.EULA == nil || current.EULA.Slug != f.EULASlug) {
+		desired.EULA = &EULA{Slug: f.EULASlug}
+	}
+
+	if f.Controlled != nil {
+		desired.Controlled = *f.Controlled
+	}
+
+	changes := diffFields(releaseFields(current), releaseFields(desired))
+	if len(changes) == 0 {
+		return PlanStep{}, false
+	}
+
+	patch := releasePatch(changes, desired)
+
+	return PlanStep{
+		Action:  PlanActionUpdate,
+		Kind:    PlanKindRelease,
+		Name:    f.Version,
+		Changes: changes,
+		apply: func(s *applyState) error {
+			release, err := s.releases().Patch(spec.ProductSlug, current.ID, patch)
+			if err != nil {
+				return err
+			}
+
+			s.release = release
+			return nil
+		},
+	}, true
+}
+
+// releasePatch returns a patch setting only the changed fields of a release
+// to their desired values.
+func releasePatch(changes []FieldChange, desired Release) ReleasePatch {
+	var patch ReleasePatch
+
+	for _, c := range changes {
+		switch c.Field {
+		case "release_type":
+			patch.ReleaseType = &desired.ReleaseType
+		case "release_date":
+			patch.ReleaseDate = String(c.To)
+		case "eula":
+			patch.EULASlug = String(c.To)
+		case "description":
+			patch.Description = String(c.To)
+		case "release_notes_url":
+			patch.ReleaseNotesURL = String(c.To)
+		case "controlled":
+			patch.Controlled = Bool(desired.Controlled)
+		case "eccn":
+			patch.ECCN = String(c.To)
+		case "license_exception":
+			patch.LicenseException = String(c.To)
+		case "end_of_support_date":
+			patch.EndOfSupportDate = String(c.To)
+		case "end_of_guidance_date":
+			patch.EndOfGuidanceDate = String(c.To)
+		case "end_of_availability_date":
+			patch.EndOfAvailabilityDate = String(c.To)
+		}
+	}
+
+	return patch
+}
+
+func (r ReleasesService) planProductFiles(spec ReleaseSpec, attached []ProductFile) ([]PlanStep, error) {
+	if spec.ProductFiles == nil {
+		return nil, nil
+	}
+
+	slug := spec.ProductSlug
+	productFiles := ProductFilesService{client: r.client}
+
+	existing, err := productFiles.List(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := map[string]ProductFile{}
+	for _, pf := range existing {
+		byKey[pf.AWSObjectKey] = pf
+	}
+
+	isAttached := map[int]bool{}
+	for _, pf := range attached {
+		isAttached[pf.ID] = true
+	}
+
+	var steps []PlanStep
+	wanted := map[string]bool{}
+
+	for _, pfSpec := range *spec.ProductFiles {
+		pfSpec := pfSpec
+		key := pfSpec.AWSObjectKey
+		wanted[key] = true
+
+		current, ok := byKey[key]
+		if !ok {
+			steps = append(steps, PlanStep{
+				Action: PlanActionCreate,
+				Kind:   PlanKindProductFile,
+				Name:   key,
+				apply: func(s *applyState) error {
+					pf, err := s.productFiles().Create(pfSpec.createConfig(slug))
+					s.productFileIDs[key] = pf.ID
+					return err
+				},
+			})
+		} else {
+			if step, ok := updateProductFileStep(slug, pfSpec, current); ok {
+				steps = append(steps, step)
+			}
+		}
+
+		if !ok || !isAttached[current.ID] {
+			id := current.ID
+			steps = append(steps, PlanStep{
+				Action: PlanActionAttach,
+				Kind:   PlanKindProductFile,
+				Name:   key,
+				apply: func(s *applyState) error {
+					if id == 0 {
+						id = s.productFileIDs[key]
+					}
+					return s.productFiles().AddToRelease(slug, s.release.ID, id)
+				},
+			})
+		}
+	}
+
+	for _, pf := range attached {
+		if wanted[pf.AWSObjectKey] {
+			continue
+		}
+
+		id := pf.ID
+		steps = append(steps, PlanStep{
+			Action: PlanActionDetach,
+			Kind:   PlanKindProductFile,
+			Name:   pf.AWSObjectKey,
+			apply: func(s *applyState) error {
+				return s.productFiles().RemoveFromRelease(slug, s.release.ID, id)
+			},
+		})
+	}
+
+	return steps, nil
+}
+
+func (p ProductFileSpec) createConfig(productSlug string) CreateProductFileConfig {
+	return CreateProductFileConfig{
+		ProductSlug:        productSlug,
+		AWSObjectKey:       p.AWSObjectKey,
+		Description:        p.Description,
+		DocsURL:            p.DocsURL,
+		FileType:           p.FileType,
+		FileVersion:        p.FileVersion,
+		IncludedFiles:      p.IncludedFiles,
+		MD5:                p.MD5,
+		Name:               p.Name,
+		Platforms:          p.Platforms,
+		ReleasedAt:         p.ReleasedAt,
+		SystemRequirements: p.SystemRequirements,
+	}
+}
+
+// updateProductFileStep returns a step patching the fields the spec sets
+// that differ from the current product file.
+func updateProductFileStep(productSlug string, spec ProductFileSpec, current ProductFile) (PlanStep, bool) {
+	var patch ProductFilePatch
+	var changes []FieldChange
+
+	fields := []struct {
+		name    string
+		current string
+		desired string
+		patch   **string
+	}{
+		{"name", current.Name, spec.Name, &patch.Name},
+		{"description", current.Description, spec.Description, &patch.Description},
+		{"docs_url", current.DocsURL, spec.DocsURL, &patch.DocsURL},
+		{"file_type", current.FileType, spec.FileType, &patch.FileType},
+		{"file_version", current.FileVersion, spec.FileVersion, &patch.FileVersion},
+		{"md5", current.MD5, spec.MD5, &patch.MD5},
+		{"released_at", current.ReleasedAt, spec.ReleasedAt, &patch.ReleasedAt},
+	}
+
+	for _, f := range fields {
+		if f.desired != "" && f.desired != f.current {
+			changes = append(changes, FieldChange{Field: f.name, From: f.current, To: f.desired})
+			*f.patch = String(f.desired)
+		}
+	}
+
+	lists := []struct {
+		name    string
+		current []string
+		desired []string
+		patch   **[]string
+	}{
+		{"included_files", current.IncludedFiles, spec.IncludedFiles, &patch.IncludedFiles},
+		{"platforms", current.Platforms, spec.Platforms, &patch.Platforms},
+		{"system_requirements", current.SystemRequirements, spec.SystemRequirements, &patch.SystemRequirements},
+	}
+
+	for _, l := range lists {
+		from, to := strings.Join(l.current, ", "), strings.Join(l.desired, ", ")
+		if len(l.desired) > 0 && from != to {
+			changes = append(changes, FieldChange{Field: l.name, From: from, To: to})
+			*l.patch = Strings(l.desired...)
+		}
+	}
+
+	if len(changes) == 0 {
+		return PlanStep{}, false
+	}
+
+	return PlanStep{
+		Action:  PlanActionUpdate,
+		Kind:    PlanKindProductFile,
+		Name:    spec.AWSObjectKey,
+		Changes: changes,
+		apply: func(s *applyState) error {
+			_, err := s.productFiles().Patch(productSlug, current.ID, patch)
+			return err
+		},
+	}, true
+}
+
+func (r ReleasesService) planFileGroups(spec ReleaseSpec, attached []FileGroup) ([]PlanStep, error) {
+	if spec.FileGroups == nil {
+		return nil, nil
+	}
+
+	slug := spec.ProductSlug
+	fileGroups := FileGroupsService{client: r.client}
+
+	var all []FileGroup
+	if len(*spec.FileGroups) > 0 {
+		var err error
+		all, err = fileGroups.List(slug)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ids := map[string]int{}
+	for _, g := range all {
+		ids[g.Name] = g.ID
+	}
+
+	var current []namedID
+	for _, g := range attached {
+		current = append(current, namedID{g.Name, g.ID})
+	}
+
+	return planAttachments(PlanKindFileGroup, *spec.FileGroups, ids, current,
+		func(s *applyState, id int) error {
+			return FileGroupsService{client: s.client}.AddToRelease(slug, s.release.ID, id)
+		},
+		func(s *applyState, id int) error {
+			return FileGroupsService{client: s.client}.RemoveFromRelease(slug, s.release.ID, id)
+		},
+	)
+}
+
+func (r ReleasesService) planUserGroups(spec ReleaseSpec, attached []UserGroup) ([]PlanStep, error) {
+	if spec.UserGroups == nil {
+		return nil, nil
+	}
+
+	slug := spec.ProductSlug
+	userGroups := UserGroupsService{client: r.client}
+
+	var all []UserGroup
+	if len(*spec.UserGroups) > 0 {
+		var err error
+		all, err = userGroups.List()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ids := map[string]int{}
+	for _, g := range all {
+		ids[g.Name] = g.ID
+	}
+
+	var current []namedID
+	for _, g := range attached {
+		current = append(current, namedID{g.Name, g.ID})
+	}
+
+	return planAttachments(PlanKindUserGroup, *spec.UserGroups, ids, current,
+		func(s *applyState, id int) error {
+			return UserGroupsService{client: s.client}.AddToRelease(slug, s.release.ID, id)
+		},
+		func(s *applyState, id int) error {
+			return UserGroupsService{client: s.client}.RemoveFromRelease(slug, s.release.ID, id)
+		},
+	)
+}
+
+func (r ReleasesService) planDependencies(spec ReleaseSpec, attached []ReleaseDependency) ([]PlanStep, error) {
+	if spec.Dependencies == nil {
+		return nil, nil
+	}
+
+	slug := spec.ProductSlug
+
+	var names []string
+	ids := map[string]int{}
+	for _, d := range *spec.Dependencies {
+		release, err := r.GetByVersion(d.ProductSlug, d.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		name := d.ProductSlug + " " + d.Version
+		names = append(names, name)
+		ids[name] = release.ID
+	}
+
+	var current []namedID
+	for _, d := range attached {
+		current = append(current, namedID{d.Release.Product.Slug + " " + d.Release.Version, d.Release.ID})
+	}
+
+	return planAttachments(PlanKindDependency, names, ids, current,
+		func(s *applyState, id int) error {
+			return ReleaseDependenciesService{client: s.client}.Add(slug, s.release.ID, id)
+		},
+		func(s *applyState, id int) error {
+			return ReleaseDependenciesService{client: s.client}.Remove(slug, s.release.ID, id)
+		},
+	)
+}
+
+func (r ReleasesService) planUpgradePaths(
+	spec ReleaseSpec,
+	releaseIDs map[string]int,
+	attached []ReleaseUpgradePath,
+) ([]PlanStep, error) {
+	if spec.UpgradePaths == nil {
+		return nil, nil
+	}
+
+	slug := spec.ProductSlug
+
+	var current []namedID
+	for _, u := range attached {
+		current = append(current, namedID{u.Release.Version, u.Release.ID})
+	}
+
+	return planAttachments(PlanKindUpgradePath, *spec.UpgradePaths, releaseIDs, current,
+		func(s *applyState, id int) error {
+			return ReleaseUpgradePathsService{client: s.client}.Add(slug, s.release.ID, id)
+		},
+		func(s *applyState, id int) error {
+			return ReleaseUpgradePathsService{client: s.client}.Remove(slug, s.release.ID, id)
+		},
+	)
+}
+
+type namedID struct {
+	name string
+	id   int
+}
+
+// planAttachments returns steps attaching the wanted items that are not
+// attached and detaching the attached items that are not wanted. ids maps
+// the names of the wanted items to their IDs.
+func planAttachments(
+	kind string,
+	wanted []string,
+	ids map[string]int,
+	attached []namedID,
+	attach func(s *applyState, id int) error,
+	detach func(s *applyState, id int) error,
+) ([]PlanStep, error) {
+	isAttached := map[int]bool{}
+	for _, a := range attached {
+		isAttached[a.id] = true
+	}
+
+	var steps []PlanStep
+	var missing []string
+	isWanted := map[int]bool{}
+
+	for _, name := range wanted {
+		id, ok := ids[name]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s %s does not exist", kind, name))
+			continue
+		}
+
+		isWanted[id] = true
+		if isAttached[id] {
+			continue
+		}
+
+		steps = append(steps, PlanStep{
+			Action: PlanActionAttach,
+			Kind:   kind,
+			Name:   name,
+			apply:  func(s *applyState) error { return attach(s, id) },
+		})
+	}
+
+	if len(missing) > 0 {
+		return nil, ErrInvalidReleaseSpec{Problems: missing}
+	}
+
+	for _, a := range attached {
+		if isWanted[a.id] {
+			continue
+		}
+
+		id := a.id
+		steps = append(steps, PlanStep{
+			Action: PlanActionDetach,
+			Kind:   kind,
+			Name:   a.name,
+			apply:  func(s *applyState) error { return detach(s, id) },
+		})
+	}
+
+	return steps, nil
+}
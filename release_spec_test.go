@@ -0,0 +1,406 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release spec", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		fake *fakePivnet
+		spec pivnet.ReleaseSpec
+
+		previousRelease pivnet.Release
+		existingFile    pivnet.ProductFile
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		fake = newFakePivnet()
+		fake.routeFrom(server)
+
+		previousRelease = fake.addRelease(productSlug, pivnet.Release{Version: "1.0.0"})
+		fake.addRelease("stemcells", pivnet.Release{Version: "3000.1"})
+		existingFile = fake.addProductFile(productSlug, pivnet.ProductFile{
+			AWSObjectKey: "product_files/Some-Product/docs.pdf",
+			Name:         "Docs",
+			FileVersion:  "1.0.0",
+		})
+		fake.fileGroups[productSlug] = []pivnet.FileGroup{{ID: 7, Name: "Stemcells"}}
+		fake.userGroups = []pivnet.UserGroup{{ID: 8, Name: "Partners"}}
+
+		spec = pivnet.ReleaseSpec{
+			ProductSlug: productSlug,
+			Release: pivnet.ReleaseSpecFields{
+				Version:     "1.1.0",
+				ReleaseType: "Minor Release",
+				EULASlug:    "some-eula",
+			},
+			ProductFiles: &[]pivnet.ProductFileSpec{
+				{AWSObjectKey: "product_files/Some-Product/tile.pivotal", Name: "Tile", FileVersion: "1.1.0"},
+				{AWSObjectKey: "product_files/Some-Product/docs.pdf", FileVersion: "1.1.0"},
+			},
+			FileGroups:   &[]string{"Stemcells"},
+			UserGroups:   &[]string{"Partners"},
+			Dependencies: &[]pivnet.DependencySpec{{ProductSlug: "stemcells", Version: "3000.1"}},
+			UpgradePaths: &[]string{"1.0.0"},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("ParseReleaseSpec", func() {
+		It("reads YAML", func() {
+			parsed, err := pivnet.ParseReleaseSpec(strings.NewReader(`
+product_slug: some-product
+release:
+  version: 1.1.0
+  controlled: false
+product_files:
+- aws_object_key: product_files/Some-Product/tile.pivotal
+  platforms: [Linux]
+dependencies:
+- product_slug: stemcells
+  version: "3000.1"
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			controlled := false
+			Expect(parsed).To(Equal(pivnet.ReleaseSpec{
+				ProductSlug: "some-product",
+				Release:     pivnet.ReleaseSpecFields{Version: "1.1.0", Controlled: &controlled},
+				ProductFiles: &[]pivnet.ProductFileSpec{
+					{AWSObjectKey: "product_files/Some-Product/tile.pivotal", Platforms: []string{"Linux"}},
+				},
+				Dependencies: &[]pivnet.DependencySpec{{ProductSlug: "stemcells", Version: "3000.1"}},
+			}))
+		})
+
+		It("reads JSON", func() {
+			parsed, err := pivnet.ParseReleaseSpec(strings.NewReader(
+				`{"product_slug":"some-product","release":{"version":"1.1.0"},"upgrade_paths":["1.0.0"]}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.UpgradePaths).To(Equal(&[]string{"1.0.0"}))
+		})
+
+		It("tells missing lists from empty ones", func() {
+			parsed, err := pivnet.ParseReleaseSpec(strings.NewReader(`
+product_slug: some-product
+release:
+  version: 1.1.0
+file_groups: []
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.FileGroups).To(Equal(&[]string{}))
+			Expect(parsed.UserGroups).To(BeNil())
+		})
+	})
+
+	Describe("Plan", func() {
+		It("plans to create the release and attach everything", func() {
+			plan, err := client.Releases.Plan(spec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.ReleaseID).To(BeZero())
+
+			var steps []string
+			for _, step := range plan.Steps {
+				steps = append(steps, fmt.Sprintf("%s %s %s", step.Action, step.Kind, step.Name))
+			}
+
+			Expect(steps).To(Equal([]string{
+				"create release 1.1.0",
+				"create product_file product_files/Some-Product/tile.pivotal",
+				"attach product_file product_files/Some-Product/tile.pivotal",
+				"update product_file product_files/Some-Product/docs.pdf",
+				"attach product_file product_files/Some-Product/docs.pdf",
+				"attach file_group Stemcells",
+				"attach user_group Partners",
+				"attach dependency stemcells 3000.1",
+				"attach upgrade_path 1.0.0",
+			}))
+
+			Expect(plan.Steps[3].Changes).To(Equal([]pivnet.FieldChange{
+				{Field: "file_version", From: "1.0.0", To: "1.1.0"},
+			}))
+
+			Expect(fake.writeCount()).To(BeZero())
+		})
+
+		Context("when the spec is invalid", func() {
+			BeforeEach(func() {
+				spec.Release.Version = ""
+				*spec.ProductFiles = append(*spec.ProductFiles, pivnet.ProductFileSpec{})
+			})
+
+			It("returns every problem", func() {
+				_, err := client.Releases.Plan(spec)
+				Expect(err).To(MatchError(pivnet.ErrInvalidReleaseSpec{Problems: []string{
+					"release.version must be set",
+					"product_files[2].aws_object_key must be set",
+				}}))
+			})
+		})
+
+		Context("when a group does not exist", func() {
+			BeforeEach(func() {
+				spec.UserGroups = &[]string{"Nobody"}
+			})
+
+			It("returns an ErrInvalidReleaseSpec", func() {
+				_, err := client.Releases.Plan(spec)
+				Expect(err).To(MatchError(pivnet.ErrInvalidReleaseSpec{Problems: []string{
+					"user_group Nobody does not exist",
+				}}))
+			})
+		})
+	})
+
+	Describe("ReleasePlan Write", func() {
+		var plan pivnet.ReleasePlan
+
+		BeforeEach(func() {
+			plan = pivnet.ReleasePlan{
+				ProductSlug: productSlug,
+				Version:     "1.1.0",
+				ReleaseID:   1234,
+				Steps: []pivnet.PlanStep{
+					{
+						Action:  pivnet.PlanActionUpdate,
+						Kind:    pivnet.PlanKindRelease,
+						Name:    "1.1.0",
+						Changes: []pivnet.FieldChange{{Field: "eula_slug", From: "old", To: "new"}},
+					},
+					{Action: pivnet.PlanActionDetach, Kind: pivnet.PlanKindUserGroup, Name: "Partners"},
+				},
+			}
+		})
+
+		It("writes text", func() {
+			var b bytes.Buffer
+			Expect(plan.Write(&b, pivnet.OutputFormatText)).To(Succeed())
+			Expect(b.String()).To(Equal(productSlug + ` 1.1.0
+  ~ update release 1.1.0
+      eula_slug: "old" -> "new"
+  < detach user_group Partners
+`))
+		})
+
+		It("writes JSON", func() {
+			var b bytes.Buffer
+			Expect(plan.Write(&b, pivnet.OutputFormatJSON)).To(Succeed())
+
+			var decoded pivnet.ReleasePlan
+			Expect(json.Unmarshal(b.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded).To(Equal(plan))
+		})
+
+		It("says when there is nothing to do", func() {
+			plan.Steps = nil
+
+			var b bytes.Buffer
+			Expect(plan.Write(&b, pivnet.OutputFormatText)).To(Succeed())
+			Expect(b.String()).To(ContainSubstring("No changes"))
+		})
+	})
+
+	Describe("Apply", func() {
+		Context("when the plan was not returned by Plan", func() {
+			It("returns an error without changing anything", func() {
+				var plan pivnet.ReleasePlan
+				err := json.Unmarshal([]byte(`{
+					"product_slug": "some-product",
+					"version": "1.1.0",
+					"steps": [{"action": "attach", "kind": "user_group", "name": "Partners"}]
+				}`), &plan)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = client.Releases.Apply(plan)
+				Expect(err).To(MatchError(
+					"cannot apply attach user_group Partners: only steps returned by Plan can be applied"))
+
+				Expect(fake.writeCount()).To(BeZero())
+			})
+		})
+
+		It("reconciles Pivnet with the spec so that planning again yields nothing", func() {
+			plan, err := client.Releases.Plan(spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			release, err := client.Releases.Apply(plan)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.Version).To(Equal("1.1.0"))
+
+			Expect(fake.attached[release.ID]["product_file"]).To(HaveLen(2))
+			Expect(fake.attached[release.ID]["file_group"]).To(Equal([]int{7}))
+			Expect(fake.attached[release.ID]["user_group"]).To(Equal([]int{8}))
+			Expect(fake.attached[release.ID]["upgrade_path"]).To(Equal([]int{previousRelease.ID}))
+			Expect(fake.productFiles[productSlug][0].FileVersion).To(Equal("1.1.0"))
+
+			replan, err := client.Releases.Plan(spec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replan.Empty()).To(BeTrue(), fmt.Sprintf("%+v", replan.Steps))
+			Expect(replan.ReleaseID).To(Equal(release.ID))
+		})
+
+		It("patches product file fields that Update does not send", func() {
+			(*spec.ProductFiles)[1].DocsURL = "https://example.com/docs"
+			(*spec.ProductFiles)[1].Platforms = []string{"Linux"}
+
+			plan, err := client.Releases.Plan(spec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Steps[3].Changes).To(Equal([]pivnet.FieldChange{
+				{Field: "docs_url", From: "", To: "https://example.com/docs"},
+				{Field: "file_version", From: "1.0.0", To: "1.1.0"},
+				{Field: "platforms", From: "", To: "Linux"},
+			}))
+
+			_, err = client.Releases.Apply(plan)
+			Expect(err).NotTo(HaveOccurred())
+
+			docs := fake.productFiles[productSlug][0]
+			Expect(docs.DocsURL).To(Equal("https://example.com/docs"))
+			Expect(docs.Platforms).To(Equal([]string{"Linux"}))
+		})
+
+		Context("when the spec only sets release metadata", func() {
+			var existing pivnet.Release
+
+			BeforeEach(func() {
+				existing = fake.addRelease(productSlug, pivnet.Release{Version: "1.1.0", ReleaseType: "Minor Release"})
+				fake.attach(existing.ID, "product_file", existingFile.ID)
+				fake.attach(existing.ID, "file_group", 7)
+				fake.attach(existing.ID, "user_group", 8)
+				fake.attach(existing.ID, "upgrade_path", previousRelease.ID)
+
+				spec = pivnet.ReleaseSpec{
+					ProductSlug: productSlug,
+					Release: pivnet.ReleaseSpecFields{
+						Version:     "1.1.0",
+						Description: "new description",
+					},
+				}
+			})
+
+			It("updates the metadata and leaves attachments alone", func() {
+				plan, err := client.Releases.Plan(spec)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Steps).To(HaveLen(1))
+				Expect(plan.Steps[0].Kind).To(Equal(pivnet.PlanKindRelease))
+
+				_, err = client.Releases.Apply(plan)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fake.release(productSlug, existing.ID).Description).To(Equal("new description"))
+				Expect(fake.attached[existing.ID]["product_file"]).To(Equal([]int{existingFile.ID}))
+				Expect(fake.attached[existing.ID]["file_group"]).To(Equal([]int{7}))
+				Expect(fake.attached[existing.ID]["user_group"]).To(Equal([]int{8}))
+				Expect(fake.attached[existing.ID]["upgrade_path"]).To(Equal([]int{previousRelease.ID}))
+			})
+		})
+
+		Context("when the spec turns off controlled on an existing release", func() {
+			var existing pivnet.Release
+
+			BeforeEach(func() {
+				existing = fake.addRelease(productSlug, pivnet.Release{
+					Version:     "1.1.0",
+					ReleaseType: "Minor Release",
+					EULA:        &pivnet.EULA{Slug: "some-eula"},
+					Controlled:  true,
+				})
+
+				controlled := false
+				spec.Release.Controlled = &controlled
+				spec.ProductFiles = nil
+				spec.FileGroups = nil
+				spec.UserGroups = nil
+				spec.Dependencies = nil
+				spec.UpgradePaths = nil
+			})
+
+			It("patches only that field and converges", func() {
+				plan, err := client.Releases.Plan(spec)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Steps).To(HaveLen(1))
+				Expect(plan.Steps[0].Changes).To(Equal([]pivnet.FieldChange{
+					{Field: "controlled", From: "true", To: "false"},
+				}))
+
+				_, err = client.Releases.Apply(plan)
+				Expect(err).NotTo(HaveOccurred())
+
+				updated := fake.release(productSlug, existing.ID)
+				Expect(updated.Controlled).To(BeFalse())
+				Expect(updated.OSSCompliant).To(BeEmpty())
+
+				replan, err := client.Releases.Plan(spec)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(replan.Empty()).To(BeTrue(), fmt.Sprintf("%+v", replan.Steps))
+			})
+		})
+
+		Context("when the release exists with things that are no longer wanted", func() {
+			BeforeEach(func() {
+				release := fake.addRelease(productSlug, pivnet.Release{Version: "1.1.0", ReleaseType: "Major Release"})
+				fake.attach(release.ID, "product_file", existingFile.ID)
+				fake.attach(release.ID, "user_group", 8)
+
+				spec.ProductFiles = &[]pivnet.ProductFileSpec{}
+				spec.UserGroups = &[]string{}
+			})
+
+			It("updates the release and detaches them", func() {
+				plan, err := client.Releases.Plan(spec)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(plan.Steps[0].Action).To(Equal(pivnet.PlanActionUpdate))
+				Expect(plan.Steps[0].Changes).To(ContainElement(pivnet.FieldChange{
+					Field: "release_type", From: "Major Release", To: "Minor Release",
+				}))
+
+				release, err := client.Releases.Apply(plan)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(release.ReleaseType).To(Equal(pivnet.ReleaseType("Minor Release")))
+
+				Expect(fake.attached[release.ID]["product_file"]).To(BeEmpty())
+				Expect(fake.attached[release.ID]["user_group"]).To(BeEmpty())
+
+				replan, err := client.Releases.Plan(spec)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(replan.Empty()).To(BeTrue(), fmt.Sprintf("%+v", replan.Steps))
+			})
+		})
+	})
+})
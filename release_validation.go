@@ -0,0 +1,132 @@
+package pivnet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const releaseDateLayout = "2006-01-02"
+
+type ErrInvalidReleaseConfig struct {
+	Problems []string `json:"problems" yaml:"problems"`
+}
+
+func (e ErrInvalidReleaseConfig) Error() string {
+	return fmt.Sprintf("invalid release config: %s", strings.Join(e.Problems, "; "))
+}
+
+// ValidateCreate checks a CreateReleaseConfig before it is sent to Pivnet,
+// which otherwise rejects mistakes with an unexplained 422. The release type
+// and EULA slug are checked against the ones Pivnet knows, dates must be
+// YYYY-MM-DD and in lifecycle order, and controlled releases must have an
+// ECCN. Every problem found is returned in an ErrInvalidReleaseConfig.
+func (r ReleasesService) ValidateCreate(config CreateReleaseConfig) error {
+	var problems []string
+
+	if config.ProductSlug == "" {
+		problems = append(problems, "product slug must be set")
+	}
+
+	if config.Version == "" {
+		problems = append(problems, "version must be set")
+	}
+
+	if config.ReleaseType == "" {
+		problems = append(problems, "release type must be set")
+	} else {
+		releaseTypes, err := ReleaseTypesService{client: r.client}.Get()
+		if err != nil {
+			return err
+		}
+
+		var known []string
+		for _, t := range releaseTypes {
+			known = append(known, string(t))
+		}
+
+		if !containsString(known, config.ReleaseType) {
+			problems = append(problems, fmt.Sprintf(
+				"release type %q is not one of: %s",
+				config.ReleaseType,
+				strings.Join(known, ", "),
+			))
+		}
+	}
+
+	if config.EULASlug == "" {
+		problems = append(problems, "EULA slug must be set")
+	} else {
+		eulas, err := EULAsService{client: r.client}.List()
+		if err != nil {
+			return err
+		}
+
+		var known []string
+		for _, e := range eulas {
+			known = append(known, e.Slug)
+		}
+
+		if !containsString(known, config.EULASlug) {
+			problems = append(problems, fmt.Sprintf("EULA slug %q does not exist", config.EULASlug))
+		}
+	}
+
+	problems = append(problems, validateReleaseDates(config)...)
+
+	if config.Controlled && config.ECCN == "" {
+		problems = append(problems, "ECCN must be set for a controlled release")
+	}
+
+	if config.LicenseException != "" && config.ECCN == "" {
+		problems = append(problems, "ECCN must be set when a license exception is")
+	}
+
+	if len(problems) > 0 {
+		return ErrInvalidReleaseConfig{Problems: problems}
+	}
+
+	return nil
+}
+
+// validateReleaseDates checks that the dates that are set parse and that
+// they come in order: release, end of support, end of technical guidance,
+// end of availability.
+func validateReleaseDates(config CreateReleaseConfig) []string {
+	var problems []string
+
+	dates := []struct {
+		name  string
+		value string
+	}{
+		{"release date", config.ReleaseDate},
+		{"end of support date", config.EndOfSupportDate},
+		{"end of guidance date", config.EndOfGuidanceDate},
+		{"end of availability date", config.EndOfAvailabilityDate},
+	}
+
+	var previousName string
+	var previous time.Time
+
+	for _, d := range dates {
+		if d.value == "" {
+			continue
+		}
+
+		t, err := time.Parse(releaseDateLayout, d.value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %q is not in YYYY-MM-DD format", d.name, d.value))
+			continue
+		}
+
+		if !previous.IsZero() && t.Before(previous) {
+			problems = append(problems, fmt.Sprintf("%s %s is before %s %s",
+				d.name, d.value, previousName, previous.Format(releaseDateLayout)))
+		}
+
+		previousName = d.name
+		previous = t
+	}
+
+	return problems
+}
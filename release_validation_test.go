@@ -0,0 +1,143 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release validation", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		config pivnet.CreateReleaseConfig
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/releases/release_types", apiPrefix),
+			ghttp.RespondWith(http.StatusOK, `{"release_types": ["Major Release","Minor Release"]}`),
+		)
+		server.RouteToHandler("GET", fmt.Sprintf("%s/eulas", apiPrefix),
+			ghttp.RespondWith(http.StatusOK, `{"eulas": [{"slug": "some-eula"}]}`),
+		)
+
+		config = pivnet.CreateReleaseConfig{
+			ProductSlug:           productSlug,
+			Version:               "1.0.0",
+			ReleaseType:           "Minor Release",
+			EULASlug:              "some-eula",
+			ReleaseDate:           "2016-01-01",
+			EndOfSupportDate:      "2017-01-01",
+			EndOfGuidanceDate:     "2017-06-01",
+			EndOfAvailabilityDate: "2017-06-01",
+			Controlled:            true,
+			ECCN:                  "5D002",
+			LicenseException:      "TSU",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("ValidateCreate", func() {
+		It("accepts a valid config", func() {
+			Expect(client.Releases.ValidateCreate(config)).To(Succeed())
+		})
+
+		Context("when the config has many problems", func() {
+			BeforeEach(func() {
+				config.Version = ""
+				config.ReleaseType = "Minor"
+				config.EULASlug = "some-eul"
+				config.ReleaseDate = "01/01/2016"
+				config.EndOfGuidanceDate = "2016-12-01"
+				config.ECCN = ""
+			})
+
+			It("returns all of them at once", func() {
+				err := client.Releases.ValidateCreate(config)
+				Expect(err).To(MatchError(pivnet.ErrInvalidReleaseConfig{Problems: []string{
+					"version must be set",
+					`release type "Minor" is not one of: Major Release, Minor Release`,
+					`EULA slug "some-eul" does not exist`,
+					`release date "01/01/2016" is not in YYYY-MM-DD format`,
+					"end of guidance date 2016-12-01 is before end of support date 2017-01-01",
+					"ECCN must be set for a controlled release",
+					"ECCN must be set when a license exception is",
+				}}))
+			})
+		})
+
+		Context("when the release type and EULA are not set", func() {
+			BeforeEach(func() {
+				config.ReleaseType = ""
+				config.EULASlug = ""
+				server.Reset()
+			})
+
+			It("reports them without calling Pivnet", func() {
+				err := client.Releases.ValidateCreate(config)
+				Expect(err).To(MatchError(pivnet.ErrInvalidReleaseConfig{Problems: []string{
+					"release type must be set",
+					"EULA slug must be set",
+				}}))
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when the dates are only partly set", func() {
+			BeforeEach(func() {
+				config.EndOfSupportDate = ""
+				config.EndOfGuidanceDate = "2015-12-31"
+			})
+
+			It("compares each with the latest earlier date that is set", func() {
+				err := client.Releases.ValidateCreate(config)
+				Expect(err).To(MatchError(pivnet.ErrInvalidReleaseConfig{Problems: []string{
+					"end of guidance date 2015-12-31 is before release date 2016-01-01",
+				}}))
+			})
+		})
+
+		Context("when listing release types fails", func() {
+			BeforeEach(func() {
+				server.RouteToHandler("GET", fmt.Sprintf("%s/releases/release_types", apiPrefix),
+					ghttp.RespondWith(http.StatusTeapot, `{"message": "foo message"}`),
+				)
+			})
+
+			It("returns the error", func() {
+				err := client.Releases.ValidateCreate(config)
+				Expect(err).To(HaveOccurred())
+				Expect(err).NotTo(BeAssignableToTypeOf(pivnet.ErrInvalidReleaseConfig{}))
+			})
+		})
+	})
+})
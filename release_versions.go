@@ -0,0 +1,200 @@
+package pivnet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+type ResolveVersionConfig struct {
+	ProductSlug string
+
+	// Constraint selects releases by semantic version, e.g. latest, 1.10.3,
+	// 1.10.x, ~1.10, ^2.1, ">=2.0 <3.0" or "1.x || 2.x". An empty
+	// constraint is the same as latest.
+	Constraint string
+
+	// ReleaseTypes and Availabilities, if set, restrict the releases
+	// considered to those with one of the given release types and
+	// availabilities, e.g. "All Users".
+	ReleaseTypes   []ReleaseType
+	Availabilities []ReleaseAvailability
+
+	// IncludePrereleases allows versions such as 2.0.0-rc.1 to match.
+	IncludePrereleases bool
+}
+
+// GetByVersion returns the release of a product with the given version. An
+// exact match is preferred; failing that a release whose version is
+// semantically equal, e.g. v1.10.3 for 1.10.3 or 1.10.0 for 1.10, is returned. ErrNotFound is
+// returned if there is none.
+func (r ReleasesService) GetByVersion(productSlug string, version string) (Release, error) {
+	releases, err := r.ListAll(context.Background(), productSlug)
+	if err != nil {
+		return Release{}, err
+	}
+
+	for _, release := range releases {
+		if release.Version == version {
+			return release, nil
+		}
+	}
+
+	if v, ok := parseReleaseVersion(version); ok {
+		for _, release := range releases {
+			rv, ok := parseReleaseVersion(release.Version)
+			if ok && rv.compare(v) == 0 {
+				return release, nil
+			}
+		}
+	}
+
+	return Release{}, newErrNotFound(fmt.Sprintf(
+		"release with version %s not found for product %s",
+		version,
+		productSlug,
+	))
+}
+
+// ResolveVersion returns the release with the highest version that satisfies
+// the config's constraint and filters. ErrNotFound is returned if there is
+// none.
+func (r ReleasesService) ResolveVersion(config ResolveVersionConfig) (Release, error) {
+	releases, err := r.ListByConstraint(config)
+	if err != nil {
+		return Release{}, err
+	}
+
+	if len(releases) == 0 {
+		return Release{}, newErrNotFound(fmt.Sprintf(
+			"no release of product %s satisfies version constraint %q",
+			config.ProductSlug,
+			config.Constraint,
+		))
+	}
+
+	return releases[0], nil
+}
+
+// ListByConstraint returns every release that satisfies the config's
+// constraint and filters, highest version first. Short versions such as 1.10
+// are treated as 1.10.0. Releases whose versions are not otherwise semantic
+// versions, such as 1.10.3.1, never satisfy a constraint and are logged.
+func (r ReleasesService) ListByConstraint(config ResolveVersionConfig) ([]Release, error) {
+	constraint, err := parseVersionConstraint(config.Constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := r.ListAll(context.Background(), config.ProductSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Release
+	for _, release := range releases {
+		if !releaseMatchesFilters(release, config.ReleaseTypes, config.Availabilities) {
+			continue
+		}
+
+		v, ok := parseReleaseVersion(release.Version)
+		if !ok {
+			r.l.Info("Skipping release with non-semantic version", logger.Data{
+				"releaseID": release.ID,
+				"version":   release.Version,
+			})
+			continue
+		}
+
+		if len(v.prerelease) > 0 && !config.IncludePrereleases {
+			continue
+		}
+
+		if constraint.matches(v) {
+			matched = append(matched, release)
+		}
+	}
+
+	SortReleasesByVersion(matched)
+
+	return matched, nil
+}
+
+func releaseMatchesFilters(release Release, releaseTypes []ReleaseType, availabilities []ReleaseAvailability) bool {
+	if len(releaseTypes) > 0 {
+		found := false
+		for _, t := range releaseTypes {
+			if release.ReleaseType == t {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(availabilities) > 0 {
+		found := false
+		for _, a := range availabilities {
+			if strings.EqualFold(string(release.Availability), string(a)) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SortReleasesByVersion sorts releases by semantic version, highest first.
+// Releases whose versions are not semantic versions sort after all others,
+// in reverse lexical order.
+func SortReleasesByVersion(releases []Release) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		return compareReleaseVersions(releases[i].Version, releases[j].Version) > 0
+	})
+}
+
+func compareReleaseVersions(a string, b string) int {
+	av, aOK := parseReleaseVersion(a)
+	bv, bOK := parseReleaseVersion(b)
+
+	switch {
+	case aOK && bOK:
+		return av.compare(bv)
+	case aOK:
+		return 1
+	case bOK:
+		return -1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// parseReleaseVersion parses the version of a release, treating short
+// versions such as 1.10 as 1.10.0. Unlike in constraints, wildcards are not
+// allowed.
+func parseReleaseVersion(s string) (semver, bool) {
+	v, ok := parseSemver(s)
+	if !ok {
+		return semver{}, false
+	}
+
+	core := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+
+	if len(strings.Split(core, ".")) != v.parts {
+		return semver{}, false
+	}
+
+	v.parts = 3
+
+	return v, true
+}
@@ -0,0 +1,263 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+var _ = Describe("PivnetClient - release versions", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		releases []pivnet.Release
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		releases = []pivnet.Release{
+			{ID: 1, Version: "1.9.0", ReleaseType: "Major Release", Availability: "All Users"},
+			{ID: 2, Version: "1.10.2", ReleaseType: "Security Release", Availability: "All Users"},
+			{ID: 3, Version: "1.10.3", ReleaseType: "Security Release", Availability: "Admins Only"},
+			{ID: 4, Version: "1.10.10", ReleaseType: "Maintenance Release", Availability: "All Users"},
+			{ID: 5, Version: "2.0.0-rc.1", ReleaseType: "Alpha Release", Availability: "All Users"},
+			{ID: 6, Version: "2.0.0", ReleaseType: "Major Release", Availability: "All Users"},
+			{ID: 7, Version: "2.1.0", ReleaseType: "Minor Release", Availability: "All Users"},
+			{ID: 8, Version: "v3.0.1", ReleaseType: "Major Release", Availability: "All Users"},
+			{ID: 9, Version: "Beta Preview", ReleaseType: "Beta Release", Availability: "All Users"},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: releases}),
+		)
+	})
+
+	Describe("GetByVersion", func() {
+		It("returns the release with the version", func() {
+			release, err := client.Releases.GetByVersion(productSlug, "1.10.3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.ID).To(Equal(3))
+		})
+
+		It("finds releases whose versions are not semantic versions", func() {
+			release, err := client.Releases.GetByVersion(productSlug, "Beta Preview")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.ID).To(Equal(9))
+		})
+
+		It("finds semantically equal versions", func() {
+			release, err := client.Releases.GetByVersion(productSlug, "3.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.ID).To(Equal(8))
+		})
+
+		Context("when there is no release with the version", func() {
+			It("returns an ErrNotFound", func() {
+				_, err := client.Releases.GetByVersion(productSlug, "1.10")
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrNotFound{}))
+			})
+		})
+	})
+
+	Context("when the releases span several pages", func() {
+		JustBeforeEach(func() {
+			releasesPath := fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug)
+
+			server.RouteToHandler("GET", releasesPath, func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Query().Get("page") == "2" {
+					ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: []pivnet.Release{
+						{ID: 10, Version: "4.0.0", ReleaseType: "Major Release", Availability: "All Users"},
+					}})(w, req)
+					return
+				}
+
+				ghttp.RespondWithJSONEncoded(http.StatusOK, map[string]interface{}{
+					"releases": releases,
+					"_links":   pivnet.Links{Next: map[string]string{"href": releasesPath + "?page=2"}},
+				})(w, req)
+			})
+		})
+
+		It("finds versions on later pages", func() {
+			release, err := client.Releases.GetByVersion(productSlug, "4.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.ID).To(Equal(10))
+		})
+
+		It("resolves constraints across every page", func() {
+			release, err := client.Releases.ResolveVersion(pivnet.ResolveVersionConfig{
+				ProductSlug: productSlug,
+				Constraint:  "latest",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.ID).To(Equal(10))
+		})
+	})
+
+	Describe("ResolveVersion", func() {
+		DescribeTable("resolves constraints to the highest matching version",
+			func(constraint string, expectedID int) {
+				release, err := client.Releases.ResolveVersion(pivnet.ResolveVersionConfig{
+					ProductSlug: productSlug,
+					Constraint:  constraint,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(release.ID).To(Equal(expectedID))
+			},
+			Entry("latest", "latest", 8),
+			Entry("empty", "", 8),
+			Entry("exact", "1.10.3", 3),
+			Entry("wildcard", "1.10.x", 4),
+			Entry("partial", "1.10", 4),
+			Entry("tilde", "~1.10", 4),
+			Entry("tilde with patch", "~1.9.0", 1),
+			Entry("caret", "^1.9", 4),
+			Entry("range", ">=2.0 <3.0", 7),
+			Entry("range with spaces", ">= 2.0 < 2.1", 6),
+			Entry("greater than partial", ">1", 8),
+			Entry("less than or equal to partial", "<=1.9", 1),
+			Entry("alternatives", "1.9.x || 2.0.x", 6),
+			Entry("not equal", ">=1.10 <2 !=1.10.10", 3),
+		)
+
+		Context("when prereleases are included", func() {
+			It("considers them", func() {
+				release, err := client.Releases.ResolveVersion(pivnet.ResolveVersionConfig{
+					ProductSlug:        productSlug,
+					Constraint:         "<2.0.0",
+					IncludePrereleases: true,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(release.ID).To(Equal(5))
+			})
+		})
+
+		Context("when filtering by release type and availability", func() {
+			It("only considers matching releases", func() {
+				release, err := client.Releases.ResolveVersion(pivnet.ResolveVersionConfig{
+					ProductSlug:    productSlug,
+					Constraint:     "1.x",
+					ReleaseTypes:   []pivnet.ReleaseType{"Security Release"},
+					Availabilities: []pivnet.ReleaseAvailability{"all users"},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(release.ID).To(Equal(2))
+			})
+		})
+
+		Context("when no release satisfies the constraint", func() {
+			It("returns an ErrNotFound", func() {
+				_, err := client.Releases.ResolveVersion(pivnet.ResolveVersionConfig{
+					ProductSlug: productSlug,
+					Constraint:  "4.x",
+				})
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrNotFound{}))
+			})
+		})
+
+		Context("when the constraint is invalid", func() {
+			It("returns an ErrInvalidVersionConstraint without listing releases", func() {
+				_, err := client.Releases.ResolveVersion(pivnet.ResolveVersionConfig{
+					ProductSlug: productSlug,
+					Constraint:  ">=banana",
+				})
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrInvalidVersionConstraint{}))
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("ListByConstraint", func() {
+		It("returns matching releases in descending semantic version order", func() {
+			matched, err := client.Releases.ListByConstraint(pivnet.ResolveVersionConfig{
+				ProductSlug: productSlug,
+				Constraint:  "1.x",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			var versions []string
+			for _, release := range matched {
+				versions = append(versions, release.Version)
+			}
+			Expect(versions).To(Equal([]string{"1.10.10", "1.10.3", "1.10.2", "1.9.0"}))
+		})
+
+		Context("when releases have short or four-part versions", func() {
+			BeforeEach(func() {
+				releases = append(releases,
+					pivnet.Release{ID: 10, Version: "1.11", ReleaseType: "Minor Release", Availability: "All Users"},
+					pivnet.Release{ID: 11, Version: "1.12.0.1", ReleaseType: "Minor Release", Availability: "All Users"},
+				)
+			})
+
+			It("treats short versions as patch zero and logs the others", func() {
+				matched, err := client.Releases.ListByConstraint(pivnet.ResolveVersionConfig{
+					ProductSlug: productSlug,
+					Constraint:  ">=1.10.10 <2.0",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var versions []string
+				for _, release := range matched {
+					versions = append(versions, release.Version)
+				}
+				Expect(versions).To(Equal([]string{"1.11", "1.10.10"}))
+
+				var skipped []interface{}
+				fake := fakeLogger.(*loggerfakes.FakeLogger)
+				for i := 0; i < fake.InfoCallCount(); i++ {
+					_, data := fake.InfoArgsForCall(i)
+					skipped = append(skipped, data[0]["version"])
+				}
+				Expect(skipped).To(ContainElement("1.12.0.1"))
+			})
+		})
+	})
+
+	Describe("SortReleasesByVersion", func() {
+		It("sorts semantic versions first, highest first", func() {
+			pivnet.SortReleasesByVersion(releases)
+
+			var versions []string
+			for _, release := range releases {
+				versions = append(versions, release.Version)
+			}
+			Expect(versions).To(Equal([]string{
+				"v3.0.1", "2.1.0", "2.0.0", "2.0.0-rc.1", "1.10.10", "1.10.3", "1.10.2", "1.9.0", "Beta Preview",
+			}))
+		})
+	})
+})
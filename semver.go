@@ -0,0 +1,310 @@
+package pivnet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a semantic version. Minor and patch may be omitted, in which
+// case parts records how many numeric components were given, e.g. 2 for
+// 1.10.
+type semver struct {
+	major, minor, patch int
+	prerelease          []string
+	parts               int
+}
+
+// parseSemver parses MAJOR[.MINOR[.PATCH]][-PRERELEASE][+BUILD], allowing a
+// leading v. Wildcard components (x, X or *) end the version, so 1.10.x
+// parses as 1.10. Build metadata is ignored.
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+
+	var v semver
+	if i := strings.Index(s, "-"); i >= 0 {
+		if s[i+1:] == "" {
+			return semver{}, false
+		}
+		v.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+
+	components := strings.Split(s, ".")
+	if len(components) > 3 {
+		return semver{}, false
+	}
+
+	numbers := []*int{&v.major, &v.minor, &v.patch}
+	for i, c := range components {
+		if c == "x" || c == "X" || c == "*" {
+			if i == 0 || v.prerelease != nil {
+				return semver{}, false
+			}
+
+			for _, rest := range components[i+1:] {
+				if rest != "x" && rest != "X" && rest != "*" {
+					return semver{}, false
+				}
+			}
+			break
+		}
+
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 0 || c == "" || (len(c) > 1 && c[0] == '0') {
+			return semver{}, false
+		}
+
+		*numbers[i] = n
+		v.parts = i + 1
+	}
+
+	return v, true
+}
+
+// complete reports whether v has all three numeric components.
+func (v semver) complete() bool {
+	return v.parts == 3
+}
+
+// next returns the smallest version greater than every version v matches
+// when treated as a range, e.g. 1.11.0 for 1.10.
+func (v semver) next() semver {
+	switch v.parts {
+	case 1:
+		return semver{major: v.major + 1, parts: 3}
+	case 2:
+		return semver{major: v.major, minor: v.minor + 1, parts: 3}
+	}
+
+	return semver{major: v.major, minor: v.minor, patch: v.patch + 1, parts: 3}
+}
+
+func (v semver) compare(o semver) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	// A prerelease has lower precedence than the release itself
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		if c := comparePrereleaseIdentifiers(v.prerelease[i], o.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return sign(len(v.prerelease) - len(o.prerelease))
+}
+
+// comparePrereleaseIdentifiers compares numeric identifiers numerically and
+// others lexically, with numeric ones ranking lower.
+func comparePrereleaseIdentifiers(a string, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		return sign(an - bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+
+	return 0
+}
+
+type ErrInvalidVersionConstraint struct {
+	Constraint string `json:"constraint" yaml:"constraint"`
+	Reason     string `json:"reason" yaml:"reason"`
+}
+
+func (e ErrInvalidVersionConstraint) Error() string {
+	return fmt.Sprintf("invalid version constraint %q: %s", e.Constraint, e.Reason)
+}
+
+// versionConstraint is a disjunction of ranges, each of which is a
+// conjunction of comparisons.
+type versionConstraint struct {
+	ranges [][]versionComparison
+}
+
+type versionComparison struct {
+	op      string
+	version semver
+}
+
+// parseVersionConstraint parses constraints such as latest, 1.10.3, 1.10.x,
+// ~1.10, ^2.1, >=2.0 <3.0 and 1.x || 2.x. Comparisons separated by spaces
+// must all hold; ranges separated by || are alternatives.
+func parseVersionConstraint(constraint string) (versionConstraint, error) {
+	invalid := func(reason string) error {
+		return ErrInvalidVersionConstraint{Constraint: constraint, Reason: reason}
+	}
+
+	var c versionConstraint
+	for _, r := range strings.Split(constraint, "||") {
+		fields := strings.Fields(r)
+
+		// Allow a space between an operator and its version, e.g. >= 2.0
+		var terms []string
+		for i := 0; i < len(fields); i++ {
+			if strings.TrimLeft(fields[i], "<>=!~^") == "" && i+1 < len(fields) {
+				terms = append(terms, fields[i]+fields[i+1])
+				i++
+				continue
+			}
+			terms = append(terms, fields[i])
+		}
+
+		if len(terms) == 0 {
+			if strings.TrimSpace(constraint) == "" {
+				terms = []string{"latest"}
+			} else {
+				return c, invalid("empty range")
+			}
+		}
+
+		var comparisons []versionComparison
+		for _, term := range terms {
+			if term == "latest" || term == "*" || term == "x" || term == "X" {
+				continue
+			}
+
+			op := term[:len(term)-len(strings.TrimLeft(term, "<>=!~^"))]
+			v, ok := parseSemver(term[len(op):])
+			if !ok {
+				return c, invalid(fmt.Sprintf("%q is not a semantic version", term[len(op):]))
+			}
+
+			expanded, err := expandComparison(op, v)
+			if err != nil {
+				return c, invalid(err.Error())
+			}
+
+			comparisons = append(comparisons, expanded...)
+		}
+
+		c.ranges = append(c.ranges, comparisons)
+	}
+
+	return c, nil
+}
+
+// expandComparison rewrites a comparison, possibly against a partial
+// version, as comparisons against complete versions.
+func expandComparison(op string, v semver) ([]versionComparison, error) {
+	lower := semver{major: v.major, minor: v.minor, patch: v.patch, prerelease: v.prerelease, parts: 3}
+
+	switch op {
+	case "", "=":
+		if v.complete() {
+			return []versionComparison{{"=", lower}}, nil
+		}
+		return []versionComparison{{">=", lower}, {"<", v.next()}}, nil
+
+	case "!=":
+		if !v.complete() {
+			return nil, fmt.Errorf("%s requires a complete version", op)
+		}
+		return []versionComparison{{"!=", lower}}, nil
+
+	case ">", "<=":
+		if v.complete() {
+			return []versionComparison{{op, lower}}, nil
+		}
+		if op == ">" {
+			return []versionComparison{{">=", v.next()}}, nil
+		}
+		return []versionComparison{{"<", v.next()}}, nil
+
+	case ">=", "<":
+		return []versionComparison{{op, lower}}, nil
+
+	case "~":
+		// ~1 allows minor updates, ~1.10 and ~1.10.3 only patch updates
+		upper := v.next()
+		if v.parts == 3 {
+			upper = semver{major: v.major, minor: v.minor + 1, parts: 3}
+		}
+		return []versionComparison{{">=", lower}, {"<", upper}}, nil
+
+	case "^":
+		// ^ allows updates that do not change the left-most non-zero component
+		var upper semver
+		switch {
+		case v.major > 0 || v.parts == 1:
+			upper = semver{major: v.major + 1, parts: 3}
+		case v.minor > 0 || v.parts == 2:
+			upper = semver{minor: v.minor + 1, parts: 3}
+		default:
+			upper = semver{patch: v.patch + 1, parts: 3}
+		}
+		return []versionComparison{{">=", lower}, {"<", upper}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %s", op)
+}
+
+func (c versionConstraint) matches(v semver) bool {
+	for _, r := range c.ranges {
+		if rangeMatches(r, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func rangeMatches(comparisons []versionComparison, v semver) bool {
+	for _, comparison := range comparisons {
+		cmp := v.compare(comparison.version)
+
+		var ok bool
+		switch comparison.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,223 @@
+package pivnet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"golang.org/x/crypto/openpgp"
+)
+
+// SignatureExtension is appended to a product file's AWS object key to form
+// the key its detached signature is uploaded to.
+const SignatureExtension = ".asc"
+
+// signatureAWSObjectKeyField is the product file field assumed to associate
+// an uploaded signature with the product file. The API does not document
+// it and may ignore it, so product files are checked for HasSignatureFile
+// afterwards.
+const signatureAWSObjectKeyField = "signature_aws_object_key"
+
+// ErrSignatureNotAssociated is returned when a signature was uploaded but
+// Pivnet does not report the product file as having one afterwards. The
+// uploaded signature is removed again.
+type ErrSignatureNotAssociated struct {
+	ProductFileID         int    `json:"product_file_id" yaml:"product_file_id"`
+	SignatureAWSObjectKey string `json:"signature_aws_object_key" yaml:"signature_aws_object_key"`
+}
+
+func (e ErrSignatureNotAssociated) Error() string {
+	return fmt.Sprintf(
+		"signature %s was uploaded but is not associated with product file %d",
+		e.SignatureAWSObjectKey,
+		e.ProductFileID,
+	)
+}
+
+type signedProductFile struct {
+	ProductFile
+	SignatureAWSObjectKey string `json:"signature_aws_object_key,omitempty"`
+}
+
+type createSignedProductFileBody struct {
+	ProductFile signedProductFile `json:"product_file"`
+}
+
+// SigningKey is an OpenPGP private key used to sign product files.
+type SigningKey struct {
+	entity *openpgp.Entity
+}
+
+// NewSigningKey reads an OpenPGP private key, either ASCII-armored or binary.
+// If the key is encrypted it is decrypted with passphrase. Only the first key
+// read is used.
+func NewSigningKey(r io.Reader, passphrase []byte) (SigningKey, error) {
+	entities, err := readEntities(r)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	if len(entities) == 0 || entities[0].PrivateKey == nil {
+		return SigningKey{}, fmt.Errorf("signing key contains no private key")
+	}
+
+	entity := entities[0]
+
+	if entity.PrivateKey.Encrypted {
+		err = entity.PrivateKey.Decrypt(passphrase)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("could not decrypt signing key: %s", err)
+		}
+	}
+
+	return SigningKey{entity: entity}, nil
+}
+
+// Sign returns an ASCII-armored detached signature of contents.
+func (k SigningKey) Sign(contents io.Reader) ([]byte, error) {
+	var signature bytes.Buffer
+
+	err := openpgp.ArmoredDetachSign(&signature, k.entity, contents, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return signature.Bytes(), nil
+}
+
+// SignFile returns an ASCII-armored detached signature of the file at
+// filePath.
+func (k SigningKey) SignFile(filePath string) ([]byte, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return k.Sign(f)
+}
+
+// Keyring returns a keyring containing the public part of the key, which can
+// verify the signatures it makes.
+func (k SigningKey) Keyring() Keyring {
+	return Keyring{entities: openpgp.EntityList{k.entity}}
+}
+
+type AddSignatureConfig struct {
+	ProductSlug   string
+	ProductFileID int
+
+	// FilePath is the local copy of the product file. It is signed with
+	// SigningKey unless Signature is set.
+	FilePath   string
+	SigningKey *SigningKey
+
+	// Signature is an existing detached signature of the product file.
+	Signature []byte
+
+	StorageEndpoint string
+}
+
+// AddSignature uploads a detached signature of an existing product file next
+// to it and associates it with the product file.
+//
+// Pivnet does not document how signatures are associated with product files,
+// so this may not work. If the product file does not have a signature
+// afterwards, the uploaded signature is removed and ErrSignatureNotAssociated
+// is returned.
+func (p ProductFilesService) AddSignature(config AddSignatureConfig) (ProductFile, error) {
+	productFile, err := p.Get(config.ProductSlug, config.ProductFileID)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	federationToken := FederationTokenService{client: p.client}
+	token, err := federationToken.GenerateFederationToken(config.ProductSlug)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	storage := newStorageClient(p.client, config.StorageEndpoint, token)
+
+	signatureKey, err := p.uploadSignature(
+		storage,
+		productFile.AWSObjectKey,
+		config.FilePath,
+		config.SigningKey,
+		config.Signature,
+	)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	productFile, err = p.patchFields(config.ProductSlug, config.ProductFileID, map[string]interface{}{
+		signatureAWSObjectKeyField: signatureKey,
+	})
+	if err != nil {
+		p.removeSignature(storage, signatureKey)
+		return ProductFile{}, err
+	}
+
+	if !productFile.HasSignatureFile {
+		p.removeSignature(storage, signatureKey)
+		return ProductFile{}, ErrSignatureNotAssociated{
+			ProductFileID:         productFile.ID,
+			SignatureAWSObjectKey: signatureKey,
+		}
+	}
+
+	return productFile, nil
+}
+
+// removeSignature deletes a signature that could not be associated with its
+// product file. Failures are only logged.
+func (p ProductFilesService) removeSignature(storage storageClient, signatureKey string) {
+	err := storage.deleteObject(signatureKey)
+	if err != nil {
+		p.client.logger.Info("Failed to remove signature", logger.Data{
+			"awsObjectKey": signatureKey,
+			"error":        err.Error(),
+		})
+	}
+}
+
+// uploadSignature uploads signature, or a signature of filePath made with
+// signingKey, to the signature key for key and returns that key.
+func (p ProductFilesService) uploadSignature(
+	storage storageClient,
+	key string,
+	filePath string,
+	signingKey *SigningKey,
+	signature []byte,
+) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("AWS object key must not be empty")
+	}
+
+	if signature == nil {
+		if signingKey == nil {
+			return "", fmt.Errorf("either a signature or a signing key must be given")
+		}
+
+		var err error
+		signature, err = signingKey.SignFile(filePath)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	signatureKey := key + SignatureExtension
+
+	p.client.logger.Info("Uploading signature", logger.Data{
+		"awsObjectKey": signatureKey,
+	})
+
+	err := storage.putObject(signatureKey, signature)
+	if err != nil {
+		return "", err
+	}
+
+	return signatureKey, nil
+}
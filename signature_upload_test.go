@@ -0,0 +1,247 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func armoredPrivateKey(entity *openpgp.Entity) []byte {
+	var b bytes.Buffer
+
+	w, err := armor.Encode(&b, openpgp.PrivateKeyType, nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(entity.SerializePrivate(w, nil)).To(Succeed())
+	Expect(w.Close()).To(Succeed())
+
+	return b.Bytes()
+}
+
+var _ = Describe("PivnetClient - signature upload", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		storageServer *ghttp.Server
+		uploaded      []byte
+
+		signer        *openpgp.Entity
+		signingKey    pivnet.SigningKey
+		productFileID int
+		fileContents  []byte
+		filePath      string
+		config        pivnet.AddSignatureConfig
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		storageServer = ghttp.NewServer()
+		uploaded = nil
+
+		signer = newTestEntity("signer")
+
+		var err error
+		signingKey, err = pivnet.NewSigningKey(bytes.NewReader(armoredPrivateKey(signer)), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		productFileID = 1234
+		fileContents = []byte("some file contents")
+
+		f, err := ioutil.TempFile("", "signature-upload")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.Write(fileContents)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+		filePath = f.Name()
+
+		config = pivnet.AddSignatureConfig{
+			ProductSlug:     productSlug,
+			ProductFileID:   productFileID,
+			FilePath:        filePath,
+			SigningKey:      &signingKey,
+			StorageEndpoint: storageServer.URL(),
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		storageServer.Close()
+		os.Remove(filePath)
+	})
+
+	Describe("NewSigningKey", func() {
+		It("signs contents verifiably with the key", func() {
+			signature, err := signingKey.Sign(bytes.NewReader(fileContents))
+			Expect(err).NotTo(HaveOccurred())
+
+			keyring := openpgp.EntityList{signer}
+			_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(fileContents), bytes.NewReader(signature))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the key has no private part", func() {
+			It("returns an error", func() {
+				_, err := pivnet.NewSigningKey(bytes.NewReader(armoredPublicKey(signer)), nil)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("AddSignature", func() {
+		var (
+			hasSignatureFile bool
+			signatureDeleted bool
+		)
+
+		BeforeEach(func() {
+			hasSignatureFile = true
+			signatureDeleted = false
+		})
+
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET",
+				fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, productFileID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+					ProductFile: pivnet.ProductFile{
+						ID:           productFileID,
+						AWSObjectKey: "product_files/Some-Product/file.tgz",
+					},
+				}),
+			)
+
+			server.RouteToHandler("POST",
+				fmt.Sprintf("%s/federation_token", apiPrefix),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FederationToken{Bucket: "some-bucket"}),
+			)
+
+			server.RouteToHandler("PATCH",
+				fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, productFileID),
+				ghttp.CombineHandlers(
+					ghttp.VerifyJSON(`{"product_file":{"signature_aws_object_key":"product_files/Some-Product/file.tgz.asc"}}`),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+						ProductFile: pivnet.ProductFile{ID: productFileID, HasSignatureFile: hasSignatureFile},
+					}),
+				),
+			)
+
+			storageServer.RouteToHandler("PUT", "/some-bucket/product_files/Some-Product/file.tgz.asc",
+				func(w http.ResponseWriter, req *http.Request) {
+					var err error
+					uploaded, err = ioutil.ReadAll(req.Body)
+					Expect(err).NotTo(HaveOccurred())
+				},
+			)
+
+			storageServer.RouteToHandler("DELETE", "/some-bucket/product_files/Some-Product/file.tgz.asc",
+				func(w http.ResponseWriter, req *http.Request) {
+					signatureDeleted = true
+					w.WriteHeader(http.StatusNoContent)
+				},
+			)
+		})
+
+		It("uploads a signature of the file next to it and associates it", func() {
+			productFile, err := client.ProductFiles.AddSignature(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(productFile.HasSignatureFile).To(BeTrue())
+
+			_, err = openpgp.CheckArmoredDetachedSignature(
+				openpgp.EntityList{signer},
+				bytes.NewReader(fileContents),
+				bytes.NewReader(uploaded),
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when a signature is given", func() {
+			BeforeEach(func() {
+				config.SigningKey = nil
+				config.Signature = []byte("some signature")
+			})
+
+			It("uploads it as is", func() {
+				_, err := client.ProductFiles.AddSignature(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uploaded).To(Equal([]byte("some signature")))
+			})
+		})
+
+		Context("when the product file has no signature afterwards", func() {
+			BeforeEach(func() {
+				hasSignatureFile = false
+			})
+
+			It("removes the uploaded signature and returns ErrSignatureNotAssociated", func() {
+				_, err := client.ProductFiles.AddSignature(config)
+				Expect(err).To(MatchError(pivnet.ErrSignatureNotAssociated{
+					ProductFileID:         productFileID,
+					SignatureAWSObjectKey: "product_files/Some-Product/file.tgz.asc",
+				}))
+
+				Expect(signatureDeleted).To(BeTrue())
+			})
+		})
+
+		Context("when neither a signature nor a signing key is given", func() {
+			BeforeEach(func() {
+				config.SigningKey = nil
+			})
+
+			It("returns an error without uploading", func() {
+				_, err := client.ProductFiles.AddSignature(config)
+				Expect(err).To(HaveOccurred())
+				Expect(storageServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when the signature cannot be uploaded", func() {
+			JustBeforeEach(func() {
+				storageServer.RouteToHandler("PUT", "/some-bucket/product_files/Some-Product/file.tgz.asc",
+					ghttp.RespondWith(http.StatusForbidden, `<Error><Code>AccessDenied</Code><Message>some message</Message></Error>`),
+				)
+			})
+
+			It("returns an ErrStorage without associating it", func() {
+				_, err := client.ProductFiles.AddSignature(config)
+				Expect(err).To(MatchError(pivnet.ErrStorage{
+					ResponseCode: http.StatusForbidden,
+					Code:         "AccessDenied",
+					Message:      "some message",
+				}))
+
+				for _, req := range server.ReceivedRequests() {
+					Expect(req.Method).NotTo(Equal("PATCH"))
+				}
+			})
+		})
+	})
+})
@@ -0,0 +1,165 @@
+package pivnet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"golang.org/x/crypto/openpgp"
+)
+
+const armorPrefix = "-----BEGIN"
+
+// Keyring is a set of OpenPGP public keys trusted to sign product files.
+type Keyring struct {
+	entities openpgp.EntityList
+}
+
+// NewKeyring reads OpenPGP public keys, either ASCII-armored or binary.
+func NewKeyring(r io.Reader) (Keyring, error) {
+	entities, err := readEntities(r)
+	if err != nil {
+		return Keyring{}, err
+	}
+
+	if len(entities) == 0 {
+		return Keyring{}, fmt.Errorf("keyring contains no keys")
+	}
+
+	return Keyring{entities: entities}, nil
+}
+
+func readEntities(r io.Reader) (openpgp.EntityList, error) {
+	br := bufio.NewReader(r)
+
+	prefix, err := br.Peek(len(armorPrefix))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if bytes.Equal(prefix, []byte(armorPrefix)) {
+		return openpgp.ReadArmoredKeyRing(br)
+	}
+
+	return openpgp.ReadKeyRing(br)
+}
+
+type ErrSignatureVerification struct {
+	ProductFileID int    `json:"product_file_id" yaml:"product_file_id"`
+	Reason        string `json:"reason" yaml:"reason"`
+}
+
+func (e ErrSignatureVerification) Error() string {
+	return fmt.Sprintf(
+		"signature verification failed for product file %d: %s",
+		e.ProductFileID,
+		e.Reason,
+	)
+}
+
+func (p ProductFile) SignatureDownloadLink() (string, error) {
+	if p.Links == nil || p.Links.SignatureFileDownload["href"] == "" {
+		return "", fmt.Errorf("Could not determine signature download link - product file %d has no signature file", p.ID)
+	}
+
+	return p.Links.SignatureFileDownload["href"], nil
+}
+
+// DownloadSignatureForRelease writes the detached signature of a product
+// file to writer.
+func (p ProductFilesService) DownloadSignatureForRelease(
+	writer io.Writer,
+	productSlug string,
+	releaseID int,
+	productFileID int,
+) error {
+	pf, err := p.GetForRelease(productSlug, releaseID, productFileID)
+	if err != nil {
+		return err
+	}
+
+	link, err := pf.SignatureDownloadLink()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.Download(writer, link)
+	return err
+}
+
+// VerifyForRelease downloads the detached signature of a product file and
+// checks that it is a valid signature of contents made by a key in keyring.
+// Verification failures are returned as ErrSignatureVerification.
+func (p ProductFilesService) VerifyForRelease(
+	contents io.Reader,
+	productSlug string,
+	releaseID int,
+	productFileID int,
+	keyring Keyring,
+) error {
+	pf, err := p.GetForRelease(productSlug, releaseID, productFileID)
+	if err != nil {
+		return err
+	}
+
+	return p.verifySignature(contents, pf, keyring)
+}
+
+func (p ProductFilesService) verifySignature(
+	contents io.Reader,
+	productFile ProductFile,
+	keyring Keyring,
+) error {
+	link, err := productFile.SignatureDownloadLink()
+	if err != nil {
+		return ErrSignatureVerification{
+			ProductFileID: productFile.ID,
+			Reason:        "product file has no signature file",
+		}
+	}
+
+	p.client.logger.Debug("Downloading signature", logger.Data{"signatureLink": link})
+
+	var signature bytes.Buffer
+	_, err = p.client.Download(&signature, link)
+	if err != nil {
+		return err
+	}
+
+	check := openpgp.CheckDetachedSignature
+	if bytes.HasPrefix(signature.Bytes(), []byte(armorPrefix)) {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+
+	signer, err := check(keyring.entities, contents, &signature)
+	if err != nil {
+		return ErrSignatureVerification{
+			ProductFileID: productFile.ID,
+			Reason:        err.Error(),
+		}
+	}
+
+	p.client.logger.Debug("Verified signature", logger.Data{
+		"productFileID": productFile.ID,
+		"keyID":         fmt.Sprintf("%X", signer.PrimaryKey.KeyId),
+	})
+
+	return nil
+}
+
+func (p ProductFilesService) verifyFile(
+	filePath string,
+	productFile ProductFile,
+	keyring Keyring,
+) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return p.verifySignature(f, productFile, keyring)
+}
@@ -0,0 +1,303 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func newTestEntity(name string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", &packet.Config{RSABits: 1024})
+	Expect(err).NotTo(HaveOccurred())
+	return entity
+}
+
+func armoredPublicKey(entity *openpgp.Entity) []byte {
+	var b bytes.Buffer
+
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(entity.Serialize(w)).To(Succeed())
+	Expect(w.Close()).To(Succeed())
+
+	return b.Bytes()
+}
+
+var _ = Describe("PivnetClient - signatures", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		signer  *openpgp.Entity
+		keyring pivnet.Keyring
+
+		releaseID     int
+		productFileID int
+		fileContents  []byte
+		signature     []byte
+		productFile   pivnet.ProductFile
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		signer = newTestEntity("signer")
+
+		var err error
+		keyring, err = pivnet.NewKeyring(bytes.NewReader(armoredPublicKey(signer)))
+		Expect(err).NotTo(HaveOccurred())
+
+		releaseID = 12
+		productFileID = 1234
+		fileContents = []byte("some file contents")
+
+		var sig bytes.Buffer
+		err = openpgp.ArmoredDetachSign(&sig, signer, bytes.NewReader(fileContents), nil)
+		Expect(err).NotTo(HaveOccurred())
+		signature = sig.Bytes()
+
+		productFile = pivnet.ProductFile{
+			ID:               productFileID,
+			AWSObjectKey:     "product-files/p/file.tgz",
+			HasSignatureFile: true,
+			Links: &pivnet.Links{
+				Download: map[string]string{
+					"href": fmt.Sprintf("/products/%s/releases/%d/product_files/%d/download", productSlug, releaseID, productFileID),
+				},
+				SignatureFileDownload: map[string]string{
+					"href": fmt.Sprintf("/products/%s/releases/%d/product_files/%d/signature_file_download", productSlug, releaseID, productFileID),
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, productFileID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: productFile}),
+		)
+
+		server.RouteToHandler("POST",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d/download", apiPrefix, productSlug, releaseID, productFileID),
+			ghttp.RespondWith(http.StatusOK, fileContents),
+		)
+
+		server.RouteToHandler("POST",
+			fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d/signature_file_download", apiPrefix, productSlug, releaseID, productFileID),
+			ghttp.RespondWith(http.StatusOK, signature),
+		)
+	})
+
+	Describe("NewKeyring", func() {
+		It("reads binary keys", func() {
+			var b bytes.Buffer
+			Expect(signer.Serialize(&b)).To(Succeed())
+
+			_, err := pivnet.NewKeyring(&b)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns an error for an empty keyring", func() {
+			_, err := pivnet.NewKeyring(bytes.NewReader(nil))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("DownloadSignatureForRelease", func() {
+		It("writes the signature to the writer", func() {
+			writer := bytes.NewBuffer(nil)
+
+			err := client.ProductFiles.DownloadSignatureForRelease(writer, productSlug, releaseID, productFileID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(writer.Bytes()).To(Equal(signature))
+		})
+
+		Context("when the product file has no signature file", func() {
+			BeforeEach(func() {
+				productFile.Links.SignatureFileDownload = nil
+			})
+
+			It("returns an error", func() {
+				err := client.ProductFiles.DownloadSignatureForRelease(bytes.NewBuffer(nil), productSlug, releaseID, productFileID)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("VerifyForRelease", func() {
+		It("accepts a valid armored signature", func() {
+			err := client.ProductFiles.VerifyForRelease(
+				bytes.NewReader(fileContents), productSlug, releaseID, productFileID, keyring)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the signature is binary", func() {
+			BeforeEach(func() {
+				var sig bytes.Buffer
+				err := openpgp.DetachSign(&sig, signer, bytes.NewReader(fileContents), nil)
+				Expect(err).NotTo(HaveOccurred())
+				signature = sig.Bytes()
+			})
+
+			It("accepts it", func() {
+				err := client.ProductFiles.VerifyForRelease(
+					bytes.NewReader(fileContents), productSlug, releaseID, productFileID, keyring)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the contents have been tampered with", func() {
+			It("returns an ErrSignatureVerification", func() {
+				err := client.ProductFiles.VerifyForRelease(
+					bytes.NewReader([]byte("other contents")), productSlug, releaseID, productFileID, keyring)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrSignatureVerification{}))
+				Expect(err.(pivnet.ErrSignatureVerification).ProductFileID).To(Equal(productFileID))
+			})
+		})
+
+		Context("when the signer is not in the keyring", func() {
+			BeforeEach(func() {
+				other := newTestEntity("other")
+
+				var err error
+				keyring, err = pivnet.NewKeyring(bytes.NewReader(armoredPublicKey(other)))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an ErrSignatureVerification", func() {
+				err := client.ProductFiles.VerifyForRelease(
+					bytes.NewReader(fileContents), productSlug, releaseID, productFileID, keyring)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrSignatureVerification{}))
+			})
+		})
+
+		Context("when the product file has no signature file", func() {
+			BeforeEach(func() {
+				productFile.Links.SignatureFileDownload = nil
+			})
+
+			It("returns an ErrSignatureVerification", func() {
+				err := client.ProductFiles.VerifyForRelease(
+					bytes.NewReader(fileContents), productSlug, releaseID, productFileID, keyring)
+				Expect(err).To(MatchError(pivnet.ErrSignatureVerification{
+					ProductFileID: productFileID,
+					Reason:        "product file has no signature file",
+				}))
+			})
+		})
+	})
+
+	Describe("downloading with a required signature", func() {
+		var options pivnet.DownloadOptions
+
+		BeforeEach(func() {
+			options = pivnet.DownloadOptions{SignatureKeyring: &keyring}
+		})
+
+		It("writes the verified contents", func() {
+			writer := bytes.NewBuffer(nil)
+
+			_, err := client.ProductFiles.DownloadForReleaseWithOptions(
+				writer, productSlug, releaseID, productFileID, options)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(writer.Bytes()).To(Equal(fileContents))
+		})
+
+		Context("when the signature is invalid", func() {
+			BeforeEach(func() {
+				signature = []byte("not a signature")
+			})
+
+			It("writes nothing and returns an ErrSignatureVerification", func() {
+				writer := bytes.NewBuffer(nil)
+
+				_, err := client.ProductFiles.DownloadForReleaseWithOptions(
+					writer, productSlug, releaseID, productFileID, options)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrSignatureVerification{}))
+
+				Expect(writer.Bytes()).To(BeEmpty())
+			})
+
+			Context("when downloading a release into a directory", func() {
+				var dir string
+
+				BeforeEach(func() {
+					var err error
+					dir, err = ioutil.TempDir("", "signatures")
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					os.RemoveAll(dir)
+				})
+
+				JustBeforeEach(func() {
+					server.RouteToHandler("GET",
+						fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+						ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{
+							ProductFiles: []pivnet.ProductFile{productFile},
+						}),
+					)
+
+					server.RouteToHandler("GET",
+						fmt.Sprintf("%s/products/%s/releases/%d/file_groups", apiPrefix, productSlug, releaseID),
+						ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{}),
+					)
+				})
+
+				It("leaves nothing in the directory", func() {
+					_, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+						ProductSlug: productSlug,
+						ReleaseID:   releaseID,
+						Dir:         dir,
+						Options:     options,
+					})
+					Expect(err).To(BeAssignableToTypeOf(pivnet.ErrSignatureVerification{}))
+
+					_, err = os.Stat(filepath.Join(dir, "file.tgz"))
+					Expect(os.IsNotExist(err)).To(BeTrue())
+
+					entries, err := ioutil.ReadDir(dir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(entries).To(BeEmpty())
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,413 @@
+package pivnet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const (
+	defaultStorageRegion = "us-east-1"
+	storageService       = "s3"
+	storageAlgorithm     = "AWS4-HMAC-SHA256"
+)
+
+type ErrStorage struct {
+	ResponseCode int    `json:"response_code" yaml:"response_code"`
+	Code         string `json:"code" yaml:"code"`
+	Message      string `json:"message" yaml:"message"`
+}
+
+func (e ErrStorage) Error() string {
+	return fmt.Sprintf("storage responded %d - %s: %s", e.ResponseCode, e.Code, e.Message)
+}
+
+// storageClient is a minimal S3 client that covers multipart uploads using
+// the temporary credentials of a FederationToken. Requests are signed with
+// AWS Signature Version 4.
+type storageClient struct {
+	// endpoint, if set, is an S3-compatible endpoint addressed path-style as
+	// <endpoint>/<bucket>/<key>. Otherwise the AWS endpoint for the token's
+	// region is addressed virtual-hosted style.
+	endpoint   string
+	token      FederationToken
+	httpClient *http.Client
+	logger     logger.Logger
+	now        func() time.Time
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUploadBody struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type listMultipartUploadsResult struct {
+	IsTruncated        bool   `xml:"IsTruncated"`
+	NextKeyMarker      string `xml:"NextKeyMarker"`
+	NextUploadIDMarker string `xml:"NextUploadIdMarker"`
+	Uploads            []struct {
+		Key       string    `xml:"Key"`
+		UploadID  string    `xml:"UploadId"`
+		Initiated time.Time `xml:"Initiated"`
+	} `xml:"Upload"`
+}
+
+type storageErrorBody struct {
+	XMLName xml.Name
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func newStorageClient(c Client, endpoint string, token FederationToken) storageClient {
+	return storageClient{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		token:      token,
+		httpClient: c.httpClient(),
+		logger:     c.logger,
+		now:        time.Now,
+	}
+}
+
+func (s storageClient) createMultipartUpload(key string) (string, error) {
+	body, err := s.do("POST", key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result initiateMultipartUploadResult
+	err = xml.Unmarshal(body, &result)
+	if err != nil {
+		return "", err
+	}
+
+	if result.UploadID == "" {
+		return "", fmt.Errorf("storage did not return an upload ID for %s", key)
+	}
+
+	return result.UploadID, nil
+}
+
+func (s storageClient) uploadPart(key string, uploadID string, partNumber int, part []byte) (string, error) {
+	query := url.Values{
+		"partNumber": {fmt.Sprintf("%d", partNumber)},
+		"uploadId":   {uploadID},
+	}
+
+	resp, err := s.request("PUT", key, query, part)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("storage did not return an ETag for part %d of %s", partNumber, key)
+	}
+
+	return etag, nil
+}
+
+func (s storageClient) completeMultipartUpload(key string, uploadID string, parts []completedPart) error {
+	b, err := xml.Marshal(completeMultipartUploadBody{Parts: parts})
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling
+		// a known-good body
+		return err
+	}
+
+	body, err := s.do("POST", key, url.Values{"uploadId": {uploadID}}, b)
+	if err != nil {
+		return err
+	}
+
+	// Completion can fail after S3 has already responded 200
+	var errBody storageErrorBody
+	if xml.Unmarshal(body, &errBody) == nil && errBody.XMLName.Local == "Error" {
+		return ErrStorage{
+			ResponseCode: http.StatusOK,
+			Code:         errBody.Code,
+			Message:      errBody.Message,
+		}
+	}
+
+	return nil
+}
+
+func (s storageClient) abortMultipartUpload(key string, uploadID string) error {
+	_, err := s.do("DELETE", key, url.Values{"uploadId": {uploadID}}, nil)
+	return err
+}
+
+func (s storageClient) putObject(key string, body []byte) error {
+	_, err := s.do("PUT", key, nil, body)
+	return err
+}
+
+func (s storageClient) deleteObject(key string) error {
+	_, err := s.do("DELETE", key, nil, nil)
+	return err
+}
+
+func (s storageClient) listMultipartUploads(prefix string) ([]MultipartUpload, error) {
+	var uploads []MultipartUpload
+
+	query := url.Values{
+		"uploads": {""},
+		"prefix":  {prefix},
+	}
+
+	for {
+		body, err := s.do("GET", "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listMultipartUploadsResult
+		err = xml.Unmarshal(body, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range result.Uploads {
+			uploads = append(uploads, MultipartUpload{
+				AWSObjectKey: u.Key,
+				UploadID:     u.UploadID,
+				Initiated:    u.Initiated,
+			})
+		}
+
+		if !result.IsTruncated {
+			return uploads, nil
+		}
+
+		query.Set("key-marker", result.NextKeyMarker)
+		query.Set("upload-id-marker", result.NextUploadIDMarker)
+	}
+}
+
+// do makes a request and returns the response body.
+func (s storageClient) do(method string, key string, query url.Values, body []byte) ([]byte, error) {
+	resp, err := s.request(method, key, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// request makes a signed request and returns the response if it has a 2XX
+// status code.
+func (s storageClient) request(method string, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := s.objectURL(key)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	s.sign(req, query, body)
+
+	s.logger.Debug("Making storage request", logger.Data{
+		"method": method,
+		"url":    redactedURL(req.URL),
+	})
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug("Storage response status code", logger.Data{"status code": resp.StatusCode})
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, storageError(resp)
+	}
+
+	return resp, nil
+}
+
+func storageError(resp *http.Response) error {
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var errBody storageErrorBody
+	xml.Unmarshal(b, &errBody)
+
+	return ErrStorage{
+		ResponseCode: resp.StatusCode,
+		Code:         errBody.Code,
+		Message:      errBody.Message,
+	}
+}
+
+func (s storageClient) region() string {
+	if s.token.Region == "" {
+		return defaultStorageRegion
+	}
+
+	return s.token.Region
+}
+
+func (s storageClient) objectURL(key string) *url.URL {
+	key = strings.TrimPrefix(key, "/")
+
+	if s.endpoint != "" {
+		u, err := url.Parse(s.endpoint)
+		if err == nil {
+			rawPath := strings.TrimSuffix(u.Path, "/") + "/" + s.token.Bucket + "/" + key
+			u.Path = rawPath
+			u.RawPath = awsURIEncode(rawPath, false)
+			return u
+		}
+	}
+
+	return &url.URL{
+		Scheme:  "https",
+		Host:    fmt.Sprintf("%s.s3.%s.amazonaws.com", s.token.Bucket, s.region()),
+		Path:    "/" + key,
+		RawPath: awsURIEncode("/"+key, false),
+	}
+}
+
+// sign adds AWS Signature Version 4 headers to req, signing every header
+// already set on it.
+func (s storageClient) sign(req *http.Request, query url.Values, body []byte) {
+	t := s.now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.token.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.token.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region(), storageService, "aws4_request"}, "/")
+
+	stringToSign := strings.Join([]string{
+		storageAlgorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.token.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.region())
+	signingKey = hmacSHA256(signingKey, storageService)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		storageAlgorithm,
+		s.token.AccessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+
+		for _, v := range values {
+			pairs = append(pairs, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes every byte of s except the unreserved
+// characters, as Signature Version 4 requires.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,520 @@
+package pivnet
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const (
+	defaultUploadPartSize    = 64 * 1024 * 1024
+	maxUploadParts           = 10000
+	defaultUploadConcurrency = 4
+	defaultPartRetries       = 3
+	defaultPartRetryInterval = time.Second
+	defaultAbandonedAfter    = 24 * time.Hour
+)
+
+type UploadProductFileConfig struct {
+	// FilePath is the local file to upload.
+	FilePath string
+
+	// ProductFile describes the product file to create once the upload
+	// completes. Its AWSObjectKey is the key the file is uploaded to and
+	// must be set. Its MD5 is computed during the upload.
+	ProductFile CreateProductFileConfig
+
+	// PartSize is the size in bytes of each part of the multipart upload.
+	// It defaults to 64 MiB and is raised if the file would otherwise need
+	// more than 10,000 parts. S3 requires every part but the last to be at
+	// least 5 MiB.
+	PartSize int64
+
+	// StorageEndpoint overrides the S3 endpoint, e.g. to upload to an
+	// S3-compatible stand-in. Objects are then addressed path-style as
+	// <endpoint>/<bucket>/<key>.
+	StorageEndpoint string
+
+	// StateFile, if set, is where the progress of the multipart upload is
+	// recorded. An interrupted upload of the same, unmodified file to the
+	// same key resumes from it, and it is removed once the upload completes.
+	// Without a state file a failed upload is aborted.
+	StateFile string
+
+	// Concurrency is the number of parts uploaded in parallel, each of which
+	// is held in memory. It defaults to 4.
+	Concurrency int
+
+	// PartRetries is the number of times a failed part is retried, waiting
+	// PartRetryInterval before the first retry and twice as long before
+	// each one after. They default to 3 and 1 second. A negative
+	// PartRetries disables retries.
+	PartRetries       int
+	PartRetryInterval time.Duration
+
+	// SigningKey, if set, is used to sign the file. The detached signature,
+	// or Signature if it is set instead, is uploaded next to the file and
+	// associated with the product file when it is created. As with
+	// AddSignature, the association relies on an undocumented field and
+	// may not work.
+	SigningKey *SigningKey
+	Signature  []byte
+}
+
+// Upload uploads a local file to the bucket Pivnet serves product files from,
+// using temporary credentials from the federation token endpoint, and then
+// creates a product file for it with the computed MD5. If a signature is
+// given but not associated with the created product file, the product file
+// is returned together with ErrSignatureNotAssociated.
+func (p ProductFilesService) Upload(config UploadProductFileConfig) (ProductFile, error) {
+	key := config.ProductFile.AWSObjectKey
+	if key == "" {
+		return ProductFile{}, fmt.Errorf("AWS object key must not be empty")
+	}
+
+	f, err := os.Open(config.FilePath)
+	if err != nil {
+		return ProductFile{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	federationToken := FederationTokenService{client: p.client}
+	token, err := federationToken.GenerateFederationToken(config.ProductFile.ProductSlug)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	storage := newStorageClient(p.client, config.StorageEndpoint, token)
+	partSize := uploadPartSize(config.PartSize, info.Size())
+
+	p.client.logger.Info("Uploading product file", logger.Data{
+		"filePath":     config.FilePath,
+		"awsObjectKey": key,
+		"size":         info.Size(),
+		"partSize":     partSize,
+	})
+
+	md5Sum, err := p.uploadFile(storage, config, f, info, partSize)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	createConfig := config.ProductFile
+	createConfig.MD5 = md5Sum
+
+	if config.SigningKey == nil && config.Signature == nil {
+		return p.Create(createConfig)
+	}
+
+	signatureKey, err := p.uploadSignature(
+		storage,
+		key,
+		config.FilePath,
+		config.SigningKey,
+		config.Signature,
+	)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	productFile, err := p.create(createConfig, signatureKey)
+	if err != nil {
+		p.removeSignature(storage, signatureKey)
+		return ProductFile{}, err
+	}
+
+	if !productFile.HasSignatureFile {
+		// The product file exists regardless, so it is returned to let the
+		// caller sign it another way or delete it
+		p.removeSignature(storage, signatureKey)
+		return productFile, ErrSignatureNotAssociated{
+			ProductFileID:         productFile.ID,
+			SignatureAWSObjectKey: signatureKey,
+		}
+	}
+
+	return productFile, nil
+}
+
+// uploadFile uploads f as a multipart upload, resuming from
+// config.StateFile when possible, and returns its MD5.
+func (p ProductFilesService) uploadFile(
+	storage storageClient,
+	config UploadProductFileConfig,
+	f *os.File,
+	info os.FileInfo,
+	partSize int64,
+) (string, error) {
+	state, resumed, err := p.startUpload(storage, config, info, partSize)
+	if err != nil {
+		return "", err
+	}
+
+	md5Sum, err := p.uploadParts(storage, state, f, info.Size(), config)
+	if err == nil {
+		err = storage.completeMultipartUpload(state.AWSObjectKey, state.UploadID, state.completedParts())
+	}
+
+	if err != nil {
+		if e, ok := err.(ErrStorage); ok && e.Code == "NoSuchUpload" && resumed {
+			p.client.logger.Info("Upload no longer exists, starting over", logger.Data{
+				"awsObjectKey": state.AWSObjectKey,
+				"uploadID":     state.UploadID,
+			})
+
+			err = os.Remove(config.StateFile)
+			if err != nil {
+				return "", err
+			}
+
+			return p.uploadFile(storage, config, f, info, partSize)
+		}
+
+		if config.StateFile == "" {
+			p.abortUpload(storage, state.AWSObjectKey, state.UploadID)
+		}
+
+		return "", err
+	}
+
+	if config.StateFile != "" {
+		err = os.Remove(config.StateFile)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return md5Sum, nil
+}
+
+// startUpload resumes the upload recorded in config.StateFile if it is for
+// the same file, key and part size, and otherwise starts a new one.
+func (p ProductFilesService) startUpload(
+	storage storageClient,
+	config UploadProductFileConfig,
+	info os.FileInfo,
+	partSize int64,
+) (*uploadState, bool, error) {
+	key := config.ProductFile.AWSObjectKey
+
+	if config.StateFile != "" {
+		state, err := readUploadState(config.StateFile)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if state != nil {
+			if state.matches(key, info, partSize) {
+				p.client.logger.Info("Resuming upload", logger.Data{
+					"awsObjectKey":   key,
+					"uploadID":       state.UploadID,
+					"completedParts": len(state.Parts),
+				})
+
+				return state, true, nil
+			}
+
+			p.client.logger.Info("Discarding upload state for a different file", logger.Data{
+				"stateFile":    config.StateFile,
+				"awsObjectKey": state.AWSObjectKey,
+				"uploadID":     state.UploadID,
+			})
+
+			p.abortUpload(storage, state.AWSObjectKey, state.UploadID)
+		}
+	}
+
+	uploadID, err := storage.createMultipartUpload(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	state := newUploadState(config.StateFile, key, uploadID, info, partSize)
+
+	err = state.save()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return state, false, nil
+}
+
+type filePart struct {
+	number int
+	data   []byte
+}
+
+// uploadParts reads the file's parts in order, adding each to the file's
+// MD5, and uploads those not yet recorded in state in parallel, recording
+// each one as it completes. Parts already uploaded are read only to be
+// hashed. It returns the MD5.
+func (p ProductFilesService) uploadParts(
+	storage storageClient,
+	state *uploadState,
+	f *os.File,
+	fileSize int64,
+	config UploadProductFileConfig,
+) (string, error) {
+	partCount := int((fileSize + state.PartSize - 1) / state.PartSize)
+	if partCount == 0 {
+		// S3 needs at least one, possibly empty, part
+		partCount = 1
+	}
+
+	pending := map[int]bool{}
+	for _, partNumber := range state.pendingParts(partCount) {
+		pending[partNumber] = true
+	}
+
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultUploadConcurrency
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return firstErr != nil
+	}
+
+	work := make(chan filePart)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for part := range work {
+				etag, err := p.uploadPart(storage, state, part.number, part.data, config)
+				if err == nil {
+					err = state.completePart(part.number, etag)
+				}
+
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	hash := md5.New()
+
+	for partNumber := 1; partNumber <= partCount && !failed(); partNumber++ {
+		offset := int64(partNumber-1) * state.PartSize
+
+		size := state.PartSize
+		if offset+size > fileSize {
+			size = fileSize - offset
+		}
+
+		part := make([]byte, size)
+		_, err := f.ReadAt(part, offset)
+		if err != nil && err != io.EOF {
+			fail(err)
+			break
+		}
+
+		hash.Write(part)
+
+		if pending[partNumber] {
+			work <- filePart{number: partNumber, data: part}
+		}
+	}
+	close(work)
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (p ProductFilesService) uploadPart(
+	storage storageClient,
+	state *uploadState,
+	partNumber int,
+	part []byte,
+	config UploadProductFileConfig,
+) (string, error) {
+	retries := config.PartRetries
+	if retries == 0 {
+		retries = defaultPartRetries
+	}
+
+	interval := config.PartRetryInterval
+	if interval <= 0 {
+		interval = defaultPartRetryInterval
+	}
+
+	for attempt := 0; ; attempt++ {
+		p.client.logger.Debug("Uploading part", logger.Data{
+			"awsObjectKey": state.AWSObjectKey,
+			"partNumber":   partNumber,
+			"size":         len(part),
+			"attempt":      attempt + 1,
+		})
+
+		etag, err := storage.uploadPart(state.AWSObjectKey, state.UploadID, partNumber, part)
+		if err == nil {
+			return etag, nil
+		}
+
+		if attempt >= retries || !isRetryableStorageError(err) {
+			return "", err
+		}
+
+		p.client.logger.Info("Retrying part", logger.Data{
+			"awsObjectKey": state.AWSObjectKey,
+			"partNumber":   partNumber,
+			"error":        err.Error(),
+		})
+
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+func (p ProductFilesService) abortUpload(storage storageClient, key string, uploadID string) {
+	err := storage.abortMultipartUpload(key, uploadID)
+	if err != nil {
+		p.client.logger.Info("Failed to abort upload", logger.Data{
+			"awsObjectKey": key,
+			"uploadID":     uploadID,
+			"error":        err.Error(),
+		})
+	}
+}
+
+// MultipartUpload is a multipart upload that has been started but neither
+// completed nor aborted.
+type MultipartUpload struct {
+	AWSObjectKey string    `json:"aws_object_key" yaml:"aws_object_key"`
+	UploadID     string    `json:"upload_id" yaml:"upload_id"`
+	Initiated    time.Time `json:"initiated" yaml:"initiated"`
+}
+
+type AbortAbandonedUploadsConfig struct {
+	// ProductSlug is the product the federation token is requested for.
+	ProductSlug string
+
+	// Prefix restricts the uploads considered to those whose key starts
+	// with it, e.g. product_files/Some-Product/. It is required, as the
+	// bucket is shared with other products.
+	Prefix string
+
+	// AbandonedAfter is how long ago an upload must have been started to be
+	// considered abandoned. It defaults to 24 hours.
+	AbandonedAfter time.Duration
+
+	StorageEndpoint string
+}
+
+// AbortAbandonedUploads aborts multipart uploads that were started long ago
+// and never completed, so that the storage their parts occupy is freed. It
+// returns the uploads it aborted.
+func (p ProductFilesService) AbortAbandonedUploads(config AbortAbandonedUploadsConfig) ([]MultipartUpload, error) {
+	if config.Prefix == "" {
+		return nil, fmt.Errorf("prefix must not be empty")
+	}
+
+	abandonedAfter := config.AbandonedAfter
+	if abandonedAfter <= 0 {
+		abandonedAfter = defaultAbandonedAfter
+	}
+
+	federationToken := FederationTokenService{client: p.client}
+	token, err := federationToken.GenerateFederationToken(config.ProductSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := newStorageClient(p.client, config.StorageEndpoint, token)
+
+	uploads, err := storage.listMultipartUploads(config.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-abandonedAfter)
+
+	var aborted []MultipartUpload
+	for _, upload := range uploads {
+		if upload.Initiated.After(cutoff) {
+			continue
+		}
+
+		p.client.logger.Info("Aborting abandoned upload", logger.Data{
+			"awsObjectKey": upload.AWSObjectKey,
+			"uploadID":     upload.UploadID,
+			"initiated":    upload.Initiated,
+		})
+
+		err = storage.abortMultipartUpload(upload.AWSObjectKey, upload.UploadID)
+		if err != nil {
+			return aborted, err
+		}
+
+		aborted = append(aborted, upload)
+	}
+
+	return aborted, nil
+}
+
+// isRetryableStorageError reports whether err could be transient. Client
+// errors other than timeouts and throttling are not.
+func isRetryableStorageError(err error) bool {
+	e, ok := err.(ErrStorage)
+	if !ok {
+		return true
+	}
+
+	switch {
+	case e.ResponseCode == 408, e.ResponseCode == 429:
+		return true
+	case e.ResponseCode >= 400 && e.ResponseCode < 500:
+		return false
+	}
+
+	return true
+}
+
+func uploadPartSize(partSize int64, fileSize int64) int64 {
+	if partSize <= 0 {
+		partSize = defaultUploadPartSize
+	}
+
+	if min := (fileSize + maxUploadParts - 1) / maxUploadParts; partSize < min {
+		partSize = min
+	}
+
+	return partSize
+}
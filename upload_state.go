@@ -0,0 +1,155 @@
+package pivnet
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// uploadState is the progress of a multipart upload, persisted as JSON so
+// that an interrupted upload can be resumed.
+type uploadState struct {
+	AWSObjectKey string         `json:"aws_object_key"`
+	UploadID     string         `json:"upload_id"`
+	FileSize     int64          `json:"file_size"`
+	FileModTime  time.Time      `json:"file_mod_time"`
+	PartSize     int64          `json:"part_size"`
+	Parts        map[int]string `json:"parts"`
+
+	mu   sync.Mutex
+	path string
+}
+
+func newUploadState(
+	path string,
+	key string,
+	uploadID string,
+	info os.FileInfo,
+	partSize int64,
+) *uploadState {
+	return &uploadState{
+		AWSObjectKey: key,
+		UploadID:     uploadID,
+		FileSize:     info.Size(),
+		FileModTime:  info.ModTime(),
+		PartSize:     partSize,
+		Parts:        map[int]string{},
+		path:         path,
+	}
+}
+
+// readUploadState returns nil if there is no state file at path.
+func readUploadState(path string) (*uploadState, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &uploadState{path: path}
+	err = json.Unmarshal(b, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Parts == nil {
+		state.Parts = map[int]string{}
+	}
+
+	return state, nil
+}
+
+func (s *uploadState) matches(key string, info os.FileInfo, partSize int64) bool {
+	return s.AWSObjectKey == key &&
+		s.FileSize == info.Size() &&
+		s.FileModTime.Equal(info.ModTime()) &&
+		s.PartSize == partSize
+}
+
+func (s *uploadState) pendingParts(partCount int) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []int
+	for partNumber := 1; partNumber <= partCount; partNumber++ {
+		if _, ok := s.Parts[partNumber]; !ok {
+			pending = append(pending, partNumber)
+		}
+	}
+
+	return pending
+}
+
+func (s *uploadState) completePart(partNumber int, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Parts[partNumber] = etag
+
+	return s.saveLocked()
+}
+
+func (s *uploadState) completedParts() []completedPart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := make([]completedPart, 0, len(s.Parts))
+	for partNumber, etag := range s.Parts {
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: etag})
+	}
+
+	sort.Sort(completedPartsByNumber(parts))
+
+	return parts
+}
+
+func (s *uploadState) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.saveLocked()
+}
+
+// saveLocked replaces the state file atomically so that a crash mid-write
+// cannot corrupt it. It does nothing if the state is not persisted.
+func (s *uploadState) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling
+		// a known-good body
+		return err
+	}
+
+	staged, err := stagingFile(s.path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged.Name())
+
+	_, err = staged.Write(b)
+
+	closeErr := staged.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(staged.Name(), s.path)
+}
+
+type completedPartsByNumber []completedPart
+
+func (c completedPartsByNumber) Len() int           { return len(c) }
+func (c completedPartsByNumber) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c completedPartsByNumber) Less(i, j int) bool { return c[i].PartNumber < c[j].PartNumber }
@@ -0,0 +1,584 @@
+package pivnet_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+)
+
+// fakeStorage is an in-memory stand-in for the S3 multipart upload API.
+type fakeStorage struct {
+	mu sync.Mutex
+
+	uploads   map[string]map[int][]byte
+	initiated map[string]time.Time
+	completed map[string][]byte
+	aborted   []string
+	requests  []*http.Request
+	nextID    int
+
+	// failPart, if set, is answered with a 500 for every attempt at it.
+	failPart int
+
+	// flakyParts maps part numbers to the number of attempts at them that
+	// are answered with a 500 before one succeeds.
+	flakyParts map[int]int
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		uploads:    map[string]map[int][]byte{},
+		initiated:  map[string]time.Time{},
+		completed:  map[string][]byte{},
+		flakyParts: map[int]int{},
+	}
+}
+
+func (f *fakeStorage) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	defer GinkgoRecover()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, req)
+
+	query := req.URL.Query()
+	uploadID := query.Get("uploadId")
+
+	if uploadID != "" && f.uploads[uploadID] == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<Error><Code>NoSuchUpload</Code><Message>some message</Message></Error>`)
+		return
+	}
+
+	switch {
+	case req.Method == "GET":
+		fmt.Fprint(w, `<ListMultipartUploadsResult>`)
+		for id, initiated := range f.initiated {
+			fmt.Fprintf(w,
+				`<Upload><Key>%s</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>`,
+				strings.TrimPrefix(query.Get("prefix")+"file.tgz", "/"),
+				id,
+				initiated.Format(time.RFC3339),
+			)
+		}
+		fmt.Fprint(w, `</ListMultipartUploadsResult>`)
+
+	case req.Method == "POST" && query["uploads"] != nil:
+		f.nextID++
+		uploadID = fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[uploadID] = map[int][]byte{}
+		f.initiated[uploadID] = time.Now()
+
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, uploadID)
+
+	case req.Method == "PUT":
+		partNumber, err := strconv.Atoi(query.Get("partNumber"))
+		Expect(err).NotTo(HaveOccurred())
+
+		if partNumber == f.failPart || f.flakyParts[partNumber] > 0 {
+			f.flakyParts[partNumber]--
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<Error><Code>InternalError</Code><Message>some message</Message></Error>`)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		Expect(err).NotTo(HaveOccurred())
+
+		f.uploads[uploadID][partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, partNumber))
+
+	case req.Method == "POST":
+		var body struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		b, err := ioutil.ReadAll(req.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(xml.Unmarshal(b, &body)).To(Succeed())
+
+		var object []byte
+		for _, part := range body.Parts {
+			Expect(part.ETag).To(Equal(fmt.Sprintf(`"etag-%d"`, part.PartNumber)))
+			object = append(object, f.uploads[uploadID][part.PartNumber]...)
+		}
+		f.completed[req.URL.Path] = object
+		delete(f.uploads, uploadID)
+		delete(f.initiated, uploadID)
+
+		fmt.Fprint(w, `<CompleteMultipartUploadResult></CompleteMultipartUploadResult>`)
+
+	case req.Method == "DELETE":
+		delete(f.uploads, uploadID)
+		delete(f.initiated, uploadID)
+		f.aborted = append(f.aborted, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeStorage) partRequests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, req := range f.requests {
+		if req.Method == "PUT" {
+			count++
+		}
+	}
+	return count
+}
+
+func (f *fakeStorage) uploadedParts(uploadID string) []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var parts []int
+	for partNumber := range f.uploads[uploadID] {
+		parts = append(parts, partNumber)
+	}
+	sort.Ints(parts)
+	return parts
+}
+
+var _ = Describe("PivnetClient - upload", func() {
+	var (
+		server     *ghttp.Server
+		client     pivnet.Client
+		token      string
+		apiAddress string
+		userAgent  string
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		storageServer *ghttp.Server
+		storage       *fakeStorage
+
+		federationToken pivnet.FederationToken
+		fileContents    []byte
+		filePath        string
+		config          pivnet.UploadProductFileConfig
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		apiAddress = server.URL()
+		token = "my-auth-token"
+		userAgent = "pivnet-resource/0.1.0 (some-url)"
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      apiAddress,
+			Token:     token,
+			UserAgent: userAgent,
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		storage = newFakeStorage()
+		storageServer = ghttp.NewServer()
+		storageServer.AllowUnhandledRequests = true
+		storageServer.UnhandledRequestStatusCode = http.StatusTeapot
+
+		federationToken = pivnet.FederationToken{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+			SessionToken:    "some-session-token",
+			Bucket:          "some-bucket",
+			Region:          "some-region",
+		}
+
+		fileContents = []byte("some file contents")
+
+		f, err := ioutil.TempFile("", "upload")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.Write(fileContents)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+		filePath = f.Name()
+
+		config = pivnet.UploadProductFileConfig{
+			FilePath: filePath,
+			ProductFile: pivnet.CreateProductFileConfig{
+				ProductSlug:  productSlug,
+				AWSObjectKey: "product_files/Some-Product/file.tgz",
+				Name:         "some-file-name",
+				FileVersion:  "some-file-version",
+			},
+			PartSize:          5,
+			StorageEndpoint:   storageServer.URL(),
+			PartRetryInterval: time.Millisecond,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		storageServer.Close()
+		os.Remove(filePath)
+	})
+
+	JustBeforeEach(func() {
+		storageServer.RouteToHandler("POST", "/some-bucket/product_files/Some-Product/file.tgz", storage.ServeHTTP)
+		storageServer.RouteToHandler("PUT", "/some-bucket/product_files/Some-Product/file.tgz", storage.ServeHTTP)
+		storageServer.RouteToHandler("DELETE", "/some-bucket/product_files/Some-Product/file.tgz", storage.ServeHTTP)
+		storageServer.RouteToHandler("GET", "/some-bucket/", storage.ServeHTTP)
+
+		server.RouteToHandler("POST",
+			fmt.Sprintf("%s/federation_token", apiPrefix),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, federationToken),
+		)
+
+		server.RouteToHandler("POST",
+			fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusCreated, pivnet.ProductFileResponse{
+				ProductFile: pivnet.ProductFile{ID: 1234},
+			}),
+		)
+	})
+
+	createRequests := func() []*http.Request {
+		var requests []*http.Request
+		for _, req := range server.ReceivedRequests() {
+			if strings.HasSuffix(req.URL.Path, "/product_files") {
+				requests = append(requests, req)
+			}
+		}
+		return requests
+	}
+
+	It("uploads the file in parts and creates the product file with its MD5", func() {
+		md5Sum := md5.Sum(fileContents)
+
+		server.RouteToHandler("POST",
+			fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+			ghttp.CombineHandlers(
+				ghttp.VerifyJSON(fmt.Sprintf(
+					`{"product_file":{"aws_object_key":"%s","file_version":"some-file-version","md5":"%s","name":"some-file-name"}}`,
+					config.ProductFile.AWSObjectKey,
+					hex.EncodeToString(md5Sum[:]),
+				)),
+				ghttp.RespondWithJSONEncoded(http.StatusCreated, pivnet.ProductFileResponse{
+					ProductFile: pivnet.ProductFile{ID: 1234},
+				}),
+			),
+		)
+
+		productFile, err := client.ProductFiles.Upload(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(productFile.ID).To(Equal(1234))
+
+		Expect(storage.completed["/some-bucket/product_files/Some-Product/file.tgz"]).To(Equal(fileContents))
+		Expect(storage.partRequests()).To(Equal(4))
+	})
+
+	It("signs storage requests with the federation token", func() {
+		_, err := client.ProductFiles.Upload(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(storage.requests).NotTo(BeEmpty())
+		for _, req := range storage.requests {
+			Expect(req.Header.Get("Authorization")).To(HavePrefix(
+				"AWS4-HMAC-SHA256 Credential=some-access-key-id/"))
+			Expect(req.Header.Get("Authorization")).To(ContainSubstring("/some-region/s3/aws4_request"))
+			Expect(req.Header.Get("X-Amz-Security-Token")).To(Equal("some-session-token"))
+			Expect(req.Header.Get("X-Amz-Content-Sha256")).NotTo(BeEmpty())
+		}
+	})
+
+	Context("when a part fails to upload", func() {
+		BeforeEach(func() {
+			storage.failPart = 2
+		})
+
+		It("aborts the upload and does not create the product file", func() {
+			_, err := client.ProductFiles.Upload(config)
+			Expect(err).To(MatchError(pivnet.ErrStorage{
+				ResponseCode: http.StatusInternalServerError,
+				Code:         "InternalError",
+				Message:      "some message",
+			}))
+
+			Expect(storage.aborted).To(Equal([]string{"upload-1"}))
+			Expect(createRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when a signature is given", func() {
+		var (
+			uploadedSignature []byte
+			signatureDeleted  bool
+			hasSignatureFile  bool
+		)
+
+		BeforeEach(func() {
+			config.Signature = []byte("some signature")
+			signatureDeleted = false
+			hasSignatureFile = true
+		})
+
+		JustBeforeEach(func() {
+			storageServer.RouteToHandler("PUT", "/some-bucket/product_files/Some-Product/file.tgz.asc",
+				func(w http.ResponseWriter, req *http.Request) {
+					var err error
+					uploadedSignature, err = ioutil.ReadAll(req.Body)
+					Expect(err).NotTo(HaveOccurred())
+				},
+			)
+			storageServer.RouteToHandler("DELETE", "/some-bucket/product_files/Some-Product/file.tgz.asc",
+				func(w http.ResponseWriter, req *http.Request) {
+					signatureDeleted = true
+					w.WriteHeader(http.StatusNoContent)
+				},
+			)
+
+			server.RouteToHandler("POST",
+				fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+				ghttp.CombineHandlers(
+					func(w http.ResponseWriter, req *http.Request) {
+						b, err := ioutil.ReadAll(req.Body)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(string(b)).To(ContainSubstring(
+							`"signature_aws_object_key":"product_files/Some-Product/file.tgz.asc"`))
+					},
+					ghttp.RespondWithJSONEncoded(http.StatusCreated, pivnet.ProductFileResponse{
+						ProductFile: pivnet.ProductFile{ID: 1234, HasSignatureFile: hasSignatureFile},
+					}),
+				),
+			)
+		})
+
+		It("uploads it next to the file and creates the product file with it", func() {
+			productFile, err := client.ProductFiles.Upload(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(productFile.HasSignatureFile).To(BeTrue())
+
+			Expect(uploadedSignature).To(Equal([]byte("some signature")))
+			Expect(createRequests()).To(HaveLen(1))
+		})
+
+		Context("when the created product file has no signature", func() {
+			BeforeEach(func() {
+				hasSignatureFile = false
+			})
+
+			It("returns the created product file with an error and removes the signature", func() {
+				productFile, err := client.ProductFiles.Upload(config)
+				Expect(err).To(MatchError(pivnet.ErrSignatureNotAssociated{
+					ProductFileID:         1234,
+					SignatureAWSObjectKey: "product_files/Some-Product/file.tgz.asc",
+				}))
+				Expect(productFile.ID).To(Equal(1234))
+
+				Expect(signatureDeleted).To(BeTrue())
+			})
+		})
+	})
+
+	Context("when the federation token cannot be generated", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("POST",
+				fmt.Sprintf("%s/federation_token", apiPrefix),
+				ghttp.RespondWith(http.StatusForbidden, `{"message":"foo message"}`),
+			)
+		})
+
+		It("returns an error without uploading", func() {
+			_, err := client.ProductFiles.Upload(config)
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+
+			Expect(storage.requests).To(BeEmpty())
+		})
+	})
+
+	Context("when the aws object key is empty", func() {
+		BeforeEach(func() {
+			config.ProductFile.AWSObjectKey = ""
+		})
+
+		It("returns an error", func() {
+			_, err := client.ProductFiles.Upload(config)
+			Expect(err).To(MatchError("AWS object key must not be empty"))
+		})
+	})
+	Context("when a part fails transiently", func() {
+		BeforeEach(func() {
+			storage.flakyParts[2] = 2
+		})
+
+		It("retries the part", func() {
+			_, err := client.ProductFiles.Upload(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(storage.completed["/some-bucket/product_files/Some-Product/file.tgz"]).To(Equal(fileContents))
+			Expect(storage.partRequests()).To(Equal(6))
+		})
+	})
+
+	Context("when uploading parts in parallel", func() {
+		BeforeEach(func() {
+			fileContents = bytes.Repeat([]byte("0123456789"), 100)
+			Expect(ioutil.WriteFile(filePath, fileContents, 0644)).To(Succeed())
+
+			config.PartSize = 7
+			config.Concurrency = 8
+		})
+
+		It("assembles the parts in order", func() {
+			_, err := client.ProductFiles.Upload(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(storage.completed["/some-bucket/product_files/Some-Product/file.tgz"]).To(Equal(fileContents))
+		})
+	})
+
+	Context("when a state file is used", func() {
+		var stateDir string
+
+		BeforeEach(func() {
+			var err error
+			stateDir, err = ioutil.TempDir("", "upload-state")
+			Expect(err).NotTo(HaveOccurred())
+
+			config.StateFile = filepath.Join(stateDir, "upload.json")
+			config.Concurrency = 1
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(stateDir)
+		})
+
+		It("resumes an interrupted upload without re-uploading completed parts", func() {
+			storage.failPart = 4
+			config.PartRetries = -1
+
+			_, err := client.ProductFiles.Upload(config)
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrStorage{}))
+
+			Expect(storage.aborted).To(BeEmpty())
+			Expect(storage.uploadedParts("upload-1")).To(Equal([]int{1, 2, 3}))
+			Expect(config.StateFile).To(BeAnExistingFile())
+
+			storage.failPart = 0
+
+			md5Sum := md5.Sum(fileContents)
+			server.RouteToHandler("POST",
+				fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+				ghttp.CombineHandlers(
+					func(w http.ResponseWriter, req *http.Request) {
+						b, err := ioutil.ReadAll(req.Body)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(string(b)).To(ContainSubstring(
+							fmt.Sprintf(`"md5":"%s"`, hex.EncodeToString(md5Sum[:]))))
+					},
+					ghttp.RespondWithJSONEncoded(http.StatusCreated, pivnet.ProductFileResponse{
+						ProductFile: pivnet.ProductFile{ID: 1234},
+					}),
+				),
+			)
+
+			_, err = client.ProductFiles.Upload(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(storage.completed["/some-bucket/product_files/Some-Product/file.tgz"]).To(Equal(fileContents))
+			Expect(storage.partRequests()).To(Equal(5))
+			Expect(createRequests()).To(HaveLen(1))
+			Expect(config.StateFile).NotTo(BeAnExistingFile())
+		})
+
+		Context("when the file has changed since the state was recorded", func() {
+			It("aborts the recorded upload and starts over", func() {
+				storage.failPart = 3
+				config.PartRetries = -1
+
+				_, err := client.ProductFiles.Upload(config)
+				Expect(err).To(HaveOccurred())
+
+				fileContents = []byte("some other file contents")
+				Expect(ioutil.WriteFile(filePath, fileContents, 0644)).To(Succeed())
+				storage.failPart = 0
+
+				_, err = client.ProductFiles.Upload(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(storage.aborted).To(Equal([]string{"upload-1"}))
+				Expect(storage.completed["/some-bucket/product_files/Some-Product/file.tgz"]).To(Equal(fileContents))
+			})
+		})
+
+		Context("when the recorded upload no longer exists", func() {
+			It("starts over", func() {
+				storage.failPart = 3
+				config.PartRetries = -1
+
+				_, err := client.ProductFiles.Upload(config)
+				Expect(err).To(HaveOccurred())
+
+				storage.failPart = 0
+				delete(storage.uploads, "upload-1")
+
+				_, err = client.ProductFiles.Upload(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(storage.completed["/some-bucket/product_files/Some-Product/file.tgz"]).To(Equal(fileContents))
+			})
+		})
+	})
+
+	Describe("AbortAbandonedUploads", func() {
+		It("aborts uploads started before the cutoff", func() {
+			storage.uploads["upload-old"] = map[int][]byte{}
+			storage.initiated["upload-old"] = time.Now().Add(-48 * time.Hour)
+			storage.uploads["upload-new"] = map[int][]byte{}
+			storage.initiated["upload-new"] = time.Now()
+
+			aborted, err := client.ProductFiles.AbortAbandonedUploads(pivnet.AbortAbandonedUploadsConfig{
+				ProductSlug:     productSlug,
+				Prefix:          "product_files/Some-Product/",
+				StorageEndpoint: storageServer.URL(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(aborted).To(HaveLen(1))
+			Expect(aborted[0].UploadID).To(Equal("upload-old"))
+			Expect(aborted[0].AWSObjectKey).To(Equal("product_files/Some-Product/file.tgz"))
+
+			Expect(storage.aborted).To(Equal([]string{"upload-old"}))
+		})
+
+		Context("when no prefix is given", func() {
+			It("returns an error without aborting anything", func() {
+				storage.uploads["upload-old"] = map[int][]byte{}
+				storage.initiated["upload-old"] = time.Now().Add(-48 * time.Hour)
+
+				_, err := client.ProductFiles.AbortAbandonedUploads(pivnet.AbortAbandonedUploadsConfig{
+					ProductSlug:     productSlug,
+					StorageEndpoint: storageServer.URL(),
+				})
+				Expect(err).To(MatchError("prefix must not be empty"))
+
+				Expect(storage.aborted).To(BeEmpty())
+			})
+		})
+	})
+})